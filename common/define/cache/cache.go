@@ -2,14 +2,62 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// NoExpiration TTL 返回值, 表示键存在但永不过期
+const NoExpiration time.Duration = -1
+
+// ErrNotFound GetBytes 时键不存在或已过期, 所有实现都返回此错误
+var ErrNotFound = errors.New("cache: key not found")
+
 type Cache interface {
 	GetDefaultExpiration() time.Duration
 	Set(ctx context.Context, key string, value interface{}) error
 	Get(ctx context.Context, key string, to interface{}) (exist bool, err error)
 	Delete(ctx context.Context, key string) error
 	SetBytes(ctx context.Context, key string, value []byte) error
+	// GetBytes 键不存在时返回 ErrNotFound
 	GetBytes(ctx context.Context, key string) ([]byte, error)
+	// SetWithExpiration 使用指定过期时间写入, expiration 为 0 时永不过期
+	SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	SetBytesWithExpiration(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	// SetNX 键不存在时写入, 返回是否写入成功
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (ok bool, err error)
+	// CompareAndDelete 键的值等于 value 时删除, 返回是否删除, 用于释放 SetNX 写入的租约
+	CompareAndDelete(ctx context.Context, key string, value interface{}) (ok bool, err error)
+	// Expire 设置键的过期时间, 键不存在时返回 false
+	Expire(ctx context.Context, key string, expiration time.Duration) (ok bool, err error)
+	// Persist 移除键的过期时间, 键不存在或没有过期时间时返回 false
+	Persist(ctx context.Context, key string) (ok bool, err error)
+	// TTL 返回键的剩余过期时间, 永不过期时返回 NoExpiration
+	TTL(ctx context.Context, key string) (ttl time.Duration, exist bool, err error)
+	// MGet 批量读取, to 与 keys 一一对应, 返回每个键是否存在
+	MGet(ctx context.Context, keys []string, to []interface{}) (exist []bool, err error)
+	// MSet 使用默认过期时间批量写入
+	MSet(ctx context.Context, values map[string]interface{}) error
+	MDelete(ctx context.Context, keys ...string) error
+	// MGetBytes 批量读取, 结果与 keys 一一对应, 不存在的键为 nil
+	MGetBytes(ctx context.Context, keys []string) ([][]byte, error)
+	MSetBytes(ctx context.Context, values map[string][]byte) error
+	// Incr 计数加一, 键不存在时从 0 开始并设置默认过期时间, 计数以十进制字符串保存
+	Incr(ctx context.Context, key string) (int64, error)
+	Decr(ctx context.Context, key string) (int64, error)
+	IncrBy(ctx context.Context, key string, delta int64) (int64, error)
+	// IncrByWithExpiration 键不存在或没有过期时间时设置 expiration 过期时间
+	IncrByWithExpiration(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error)
+	// Clear 删除当前分组下的所有键, 未设置分组时返回错误
+	Clear(ctx context.Context) error
+	// DeleteByPrefix 删除当前分组下以 prefix 开头的所有键, 分组和 prefix 都为空时返回错误
+	DeleteByPrefix(ctx context.Context, prefix string) error
+}
+
+// TagCache 支持按标签批量失效的缓存
+type TagCache interface {
+	Cache
+	// SetWithTags 使用默认过期时间写入并为键添加标签
+	SetWithTags(ctx context.Context, key string, value interface{}, tags ...string) error
+	// DeleteByTags 删除带有任一标签的所有键
+	DeleteByTags(ctx context.Context, tags ...string) error
 }
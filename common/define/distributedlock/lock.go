@@ -1,6 +1,38 @@
 package distributedlock
 
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrLocked TryLock 时锁已被其他持有者占用
+	ErrLocked = errors.New("distributedlock: locked by another holder")
+	// ErrNotHeld 解锁时锁未被当前实例持有
+	ErrNotHeld = errors.New("distributedlock: lock not held")
+)
+
 type DistributedLock interface {
-	Lock()error
-	Unlock()error
-}
\ No newline at end of file
+	Lock() error
+	Unlock() error
+	// LockContext 阻塞直到获取锁或 ctx 结束
+	LockContext(ctx context.Context) error
+	// TryLock 尝试获取锁, 锁被占用时立即返回 ErrLocked
+	TryLock(ctx context.Context) error
+	// WithLock 获取锁后执行 fn, 无论 fn 是否出错都会释放锁
+	WithLock(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithLock 使用 lock.LockContext 获取锁后执行 fn, fn 返回或 panic 时释放锁
+// fn 的错误优先于解锁错误返回
+func WithLock(ctx context.Context, lock DistributedLock, fn func(ctx context.Context) error) (err error) {
+	if err = lock.LockContext(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+	return fn(ctx)
+}
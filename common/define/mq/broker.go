@@ -1,5 +1,21 @@
 package mq
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoResponders Request 时没有响应方订阅该主题
+var ErrNoResponders = errors.New("mq: no responders")
+
+// ResponseError 响应方处理请求时返回的错误
+type ResponseError struct {
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
 
 type Subscriber interface {
 	Unsubscribe() error
@@ -13,11 +29,52 @@ type MQ interface {
 
 type Producer interface {
 	Publish(topic string, data interface{}) error
+	// Request 发送请求并等待第一个响应解码到 reply, ctx 控制超时
+	// 响应方返回错误时返回 *ResponseError
+	Request(ctx context.Context, topic string, data interface{}, reply interface{}) error
+	// PublishMsg 发布消息信封, 未设置的 ID 和 Timestamp 自动生成, ctx 中的 PropagatedHeaders 写入消息头
+	// 补全的字段写在 msg 的副本上, 不修改 msg
+	PublishMsg(ctx context.Context, topic string, msg *Message) error
 }
 
 type Consumer interface {
 	Subscribe(topic string, cb CallBack) (Subscriber, error)
 	GroupSubscribe(topic, group string, cb CallBack) (Subscriber, error)
+	// Respond 订阅请求, handler 的返回值作为响应, group 不为空时同组内只有一个响应方处理
+	Respond(topic, group string, handler Responder) (Subscriber, error)
+	SubscribeMsg(topic string, handler MsgHandler) (Subscriber, error)
+	GroupSubscribeMsg(topic, group string, handler MsgHandler) (Subscriber, error)
+	// SubscribeRaw 与 SubscribeMsg 相同, 但不解码消息, msg.Data 为原始的 []byte
+	SubscribeRaw(topic string, handler MsgHandler) (Subscriber, error)
+	GroupSubscribeRaw(topic, group string, handler MsgHandler) (Subscriber, error)
 }
 
-type CallBack func(topic string,message interface{})
\ No newline at end of file
+type CallBack func(topic string,message interface{})
+
+// Responder 处理请求, 返回的值或错误会发送给请求方
+type Responder func(topic string, request interface{}) (interface{}, error)
+
+// Delivery 持久化消息的确认操作, 回调返回前后都可以调用
+// 既不确认也不拒绝的消息在确认超时后重新投递
+type Delivery interface {
+	// Ack 处理成功, 不再投递
+	Ack() error
+	// Nak 处理失败, 按退避延迟重新投递
+	Nak() error
+	// Term 消息无法处理, 不再投递
+	Term() error
+	// Attempt 当前是第几次投递, 从 1 开始
+	Attempt() int
+}
+
+type DurableCallBack func(topic string, message interface{}, delivery Delivery)
+
+// DurableConsumer 需要确认的订阅, 未确认的消息按配置重新投递
+type DurableConsumer interface {
+	// DurableSubscribe 使用临时消费者, 投递进度只在订阅期间保留,
+	// 每次订阅都从 stream 中保留的最早消息重新投递, 需要断点续投时使用 DurableGroupSubscribe
+	DurableSubscribe(topic string, cb DurableCallBack) (Subscriber, error)
+	// DurableGroupSubscribe 组名即持久化消费者名称, 同组的订阅共享投递进度并负载均衡,
+	// 消费者离线期间发布的消息在重新订阅后继续投递
+	DurableGroupSubscribe(topic, group string, cb DurableCallBack) (Subscriber, error)
+}
@@ -0,0 +1,118 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	HeaderTraceID = "X-Trace-Id"
+	HeaderApp     = "X-App" // 租户
+	HeaderEdgeID  = "X-Edge-Id"
+)
+
+// PropagatedHeaders 发布时从 ctx 写入消息头, 接收时从消息头写回 ctx 的头部
+var PropagatedHeaders = []string{HeaderTraceID, HeaderApp, HeaderEdgeID}
+
+// Message 消息信封, 发布时 Data 为任意可编码的值, 接收时为解码后的值(SubscribeRaw 时为原始的 []byte)
+type Message struct {
+	ID        string
+	Topic     string
+	Header    map[string]string
+	Data      interface{}
+	Timestamp time.Time
+	Reply     string // 请求消息的回复主题
+}
+
+// Clone 复制消息信封, 副本的头部为独立的 map, Data 不做深拷贝
+func (m *Message) Clone() *Message {
+	c := *m
+	if m.Header != nil {
+		c.Header = make(map[string]string, len(m.Header))
+		for key, value := range m.Header {
+			c.Header[key] = value
+		}
+	}
+	return &c
+}
+
+// MsgHandler 接收消息信封, ctx 中带有消息头传递的 PropagatedHeaders
+type MsgHandler func(ctx context.Context, msg *Message)
+
+// NewMessageID 生成随机的消息 ID
+func NewMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type headerKey struct{}
+
+// WithHeader 返回带有头部的 ctx, 发布消息时 PropagatedHeaders 中的头部会自动写入消息头
+func WithHeader(ctx context.Context, key, value string) context.Context {
+	old, _ := ctx.Value(headerKey{}).(map[string]string)
+	header := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		header[k] = v
+	}
+	header[key] = value
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// HeaderFromContext 读取 WithHeader 写入的头部
+func HeaderFromContext(ctx context.Context, key string) string {
+	header, _ := ctx.Value(headerKey{}).(map[string]string)
+	return header[key]
+}
+
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return WithHeader(ctx, HeaderTraceID, traceID)
+}
+
+func TraceID(ctx context.Context) string {
+	return HeaderFromContext(ctx, HeaderTraceID)
+}
+
+func WithApp(ctx context.Context, app string) context.Context {
+	return WithHeader(ctx, HeaderApp, app)
+}
+
+func App(ctx context.Context) string {
+	return HeaderFromContext(ctx, HeaderApp)
+}
+
+func WithEdgeID(ctx context.Context, edgeID string) context.Context {
+	return WithHeader(ctx, HeaderEdgeID, edgeID)
+}
+
+func EdgeID(ctx context.Context) string {
+	return HeaderFromContext(ctx, HeaderEdgeID)
+}
+
+// InjectHeader 把 ctx 中的 PropagatedHeaders 写入 header, 不覆盖 header 中已有的值
+func InjectHeader(ctx context.Context, header map[string]string) map[string]string {
+	if header == nil {
+		header = map[string]string{}
+	}
+	for _, key := range PropagatedHeaders {
+		if _, ok := header[key]; ok {
+			continue
+		}
+		if value := HeaderFromContext(ctx, key); value != "" {
+			header[key] = value
+		}
+	}
+	return header
+}
+
+// ExtractHeader 把 header 中的 PropagatedHeaders 写回 ctx
+func ExtractHeader(ctx context.Context, header map[string]string) context.Context {
+	for _, key := range PropagatedHeaders {
+		if value := header[key]; value != "" {
+			ctx = WithHeader(ctx, key, value)
+		}
+	}
+	return ctx
+}
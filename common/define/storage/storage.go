@@ -2,9 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
+// ErrObjectNotFound GetObject 时对象不存在, 实现可能包装该错误, 需使用 errors.Is 判断
+var ErrObjectNotFound = errors.New("storage: object not found")
+
 type Storage interface {
 	PutObject(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string) error
 	GetObject(ctx context.Context, objectName string) ([]byte, error)
@@ -12,3 +16,13 @@ type Storage interface {
 	RemoveObjects(ctx context.Context, objectNameChan <-chan string) error
 	RemoveByPrefix(ctx context.Context, prefix string) error
 }
+
+// MetadataStorage 支持对象自定义元数据的存储, 元数据随对象一起覆盖和删除
+// 元数据的键使用 http.CanonicalHeaderKey 格式, 例如 "Fencing-Token"
+type MetadataStorage interface {
+	Storage
+	// PutObjectWithMetadata 写入对象并替换其元数据
+	PutObjectWithMetadata(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string, metadata map[string]string) error
+	// GetMetadata 读取对象元数据, 对象不存在时返回 ErrObjectNotFound
+	GetMetadata(ctx context.Context, objectName string) (map[string]string, error)
+}
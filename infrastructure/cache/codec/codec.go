@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/huskar-t/gopher/infrastructure/json"
+	"github.com/vmihailenco/msgpack/v5"
+	"sync"
+)
+
+// 编码后的数据以两个字节的头部开始, 第一个字节固定为 0xC1, 第二个字节为编码器 ID.
+// 0xC1 在 msgpack 中未使用, 不是合法的 UTF-8 首字节, 也不是 gob 数据流长度的首字节(0x00-0x7F 或 0xF8-0xFF),
+// 因此可以和没有头部的旧数据区分
+const headerMagic byte = 0xC1
+
+const (
+	GobID     byte = 1
+	JSONID    byte = 2
+	MsgpackID byte = 3
+)
+
+// Codec 缓存值编码器
+type Codec interface {
+	// ID 编码器 ID, 不能为 0, 写入数据头部
+	ID() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	lock   sync.RWMutex
+	codecs = map[byte]Codec{}
+)
+
+// Register 注册编码器, 读取时根据数据头部查找
+func Register(c Codec) {
+	if c.ID() == 0 {
+		panic(fmt.Sprintf("codec: invalid id %d", c.ID()))
+	}
+	lock.Lock()
+	codecs[c.ID()] = c
+	lock.Unlock()
+}
+
+// Get 根据 ID 获取已注册的编码器
+func Get(id byte) (Codec, bool) {
+	lock.RLock()
+	c, ok := codecs[id]
+	lock.RUnlock()
+	return c, ok
+}
+
+// Encode 使用 c 编码并添加头部
+func Encode(c Codec, v interface{}) ([]byte, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, len(data)+2)
+	payload[0] = headerMagic
+	payload[1] = c.ID()
+	copy(payload[2:], data)
+	return payload, nil
+}
+
+// Decode 根据头部选择编码器解码, 没有头部的数据使用 fallback 解码
+func Decode(data []byte, v interface{}, fallback Codec) error {
+	if len(data) > 1 && data[0] == headerMagic {
+		if c, ok := Get(data[1]); ok {
+			return c.Unmarshal(data[2:], v)
+		}
+	}
+	return fallback.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return GobID }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return JSONID }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte { return MsgpackID }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+var (
+	Gob     Codec = gobCodec{}
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{}
+)
+
+func init() {
+	Register(Gob)
+	Register(JSON)
+	Register(Msgpack)
+}
@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type point struct {
+	Name  string
+	Value float64
+	Tags  map[string]string
+}
+
+func TestCodec(t *testing.T) {
+	v := point{Name: "temperature", Value: 21.5, Tags: map[string]string{"edge": "e1"}}
+	for _, c := range []Codec{Gob, JSON, Msgpack} {
+		payload, err := Encode(c, v)
+		assert.NoError(t, err)
+		assert.Equal(t, headerMagic, payload[0])
+		assert.Equal(t, c.ID(), payload[1])
+
+		// 带头部的数据不依赖 fallback
+		for _, fallback := range []Codec{Gob, JSON, Msgpack} {
+			var got point
+			assert.NoError(t, Decode(payload, &got, fallback))
+			assert.Equal(t, v, got)
+		}
+
+		// 没有头部的旧数据使用 fallback 解码
+		legacy, err := c.Marshal(v)
+		assert.NoError(t, err)
+		var got point
+		assert.NoError(t, Decode(legacy, &got, c))
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestLegacyMsgpackMap(t *testing.T) {
+	// msgpack fixmap 以 0x80-0x8F 开头
+	v := map[string]int{"a": 1, "b": 2, "c": 3}
+	legacy, err := Msgpack.Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x83), legacy[0])
+	var got map[string]int
+	assert.NoError(t, Decode(legacy, &got, Msgpack))
+	assert.Equal(t, v, got)
+}
@@ -0,0 +1,212 @@
+package loader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"golang.org/x/sync/singleflight"
+	"reflect"
+	"time"
+)
+
+// ErrNotFound load 函数返回此错误表示数据不存在, 开启负缓存时会被缓存, 与 cache.ErrNotFound 相同
+var ErrNotFound = cache.ErrNotFound
+
+const (
+	negativeSuffix = ":__nil"
+	leaseSuffix    = ":__lease"
+)
+
+type Option func(l *Loader)
+
+// WithNegativeExpiration 缓存 ErrNotFound 结果, 过期前不再调用 load 函数
+func WithNegativeExpiration(expiration time.Duration) Option {
+	return func(l *Loader) {
+		l.negativeExpiration = expiration
+	}
+}
+
+// WithLease 加载前在缓存中抢占租约, 集群内只有一个实例执行 load 函数,
+// 其他实例每隔 retryInterval 读取一次缓存, 租约过期后仍未读到时自行加载
+func WithLease(expiration, retryInterval time.Duration) Option {
+	return func(l *Loader) {
+		l.leaseExpiration = expiration
+		l.leaseRetryInterval = retryInterval
+	}
+}
+
+// Loader 在 cache.Cache 之上实现读穿透, 同一进程内相同 key 的并发加载只执行一次
+type Loader struct {
+	cache              cache.Cache
+	group              singleflight.Group
+	negativeExpiration time.Duration
+	leaseExpiration    time.Duration
+	leaseRetryInterval time.Duration
+}
+
+func New(c cache.Cache, opts ...Option) *Loader {
+	l := &Loader{cache: c}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.leaseExpiration > 0 && l.leaseRetryInterval <= 0 {
+		l.leaseRetryInterval = 50 * time.Millisecond
+	}
+	return l
+}
+
+// GetOrLoad 读取缓存到 to, 未命中时调用 load 加载并写入缓存
+// to 必须为指针, load 返回值需要能赋值给 to 指向的类型(或为指向该类型的指针)
+func (l *Loader) GetOrLoad(ctx context.Context, key string, to interface{}, load func() (interface{}, error)) error {
+	if v := reflect.ValueOf(to); v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("loader: to must be a non-nil pointer")
+	}
+	exist, err := l.cache.Get(ctx, key, to)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return nil
+	}
+	if l.negativeExpiration > 0 {
+		var notFound bool
+		exist, err = l.cache.Get(ctx, key+negativeSuffix, &notFound)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return ErrNotFound
+		}
+	}
+	// 共享的加载不受发起者 ctx 取消的影响, 每个调用方只等待自己的 ctx
+	// load 函数 panic 时转换为错误返回给所有等待的调用方
+	ch := l.group.DoChan(key, func() (v interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				v, err = nil, fmt.Errorf("loader: load %s panic: %v", key, r)
+			}
+		}()
+		return l.load(detachedContext{ctx}, key, reflect.TypeOf(to).Elem(), load)
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return r.Err
+		}
+		return assign(to, r.Val)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Loader) load(ctx context.Context, key string, typ reflect.Type, load func() (interface{}, error)) (interface{}, error) {
+	if l.leaseExpiration > 0 {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		acquired, err := l.cache.SetNX(ctx, key+leaseSuffix, token, l.leaseExpiration)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			// 加载超过租约时间时租约可能已被其他实例获取, 只释放自己的租约
+			defer l.cache.CompareAndDelete(ctx, key+leaseSuffix, token)
+		} else if v, ok, err := l.wait(ctx, key, typ); err != nil || ok {
+			return v, err
+		}
+	}
+	v, err := load()
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && l.negativeExpiration > 0 {
+			if err := l.cache.SetWithExpiration(ctx, key+negativeSuffix, true, l.negativeExpiration); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	if err = l.cache.Set(ctx, key, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// wait 等待持有租约的实例写入缓存
+func (l *Loader) wait(ctx context.Context, key string, typ reflect.Type) (interface{}, bool, error) {
+	deadline := time.NewTimer(l.leaseExpiration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(l.leaseRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-deadline.C:
+			return nil, false, nil
+		case <-ticker.C:
+			to := reflect.New(typ)
+			exist, err := l.cache.Get(ctx, key, to.Interface())
+			if err != nil {
+				return nil, false, err
+			}
+			if exist {
+				return to.Elem().Interface(), true, nil
+			}
+			if l.negativeExpiration > 0 {
+				var notFound bool
+				if exist, err = l.cache.Get(ctx, key+negativeSuffix, &notFound); err != nil {
+					return nil, false, err
+				}
+				if exist {
+					return nil, false, ErrNotFound
+				}
+			}
+		}
+	}
+}
+
+// detachedContext 保留 ctx 中的值, 不继承取消和超时
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func assign(to interface{}, v interface{}) error {
+	dst := reflect.ValueOf(to).Elem()
+	src := reflect.ValueOf(v)
+	if !src.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	if src.Kind() == reflect.Ptr && !src.IsNil() && src.Elem().Type().AssignableTo(dst.Type()) {
+		dst.Set(src.Elem())
+		return nil
+	}
+	return fmt.Errorf("loader: cannot assign %s to %s", src.Type(), dst.Type())
+}
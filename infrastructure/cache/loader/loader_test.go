@@ -0,0 +1,119 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"github.com/huskar-t/gopher/infrastructure/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	c := memory.NewCache("loader", time.Minute, 0, 0)
+	defer c.Close()
+	l := New(c)
+
+	var calls int32
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	// 发起加载的调用方取消后, 其他调用方仍然得到结果
+	canceled, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		var v string
+		first <- l.GetOrLoad(canceled, "k", &v, load)
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, l.GetOrLoad(context.Background(), "k", &results[i], load))
+		}(i)
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-first)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, []string{"value", "value", "value", "value", "value"}, results)
+}
+
+func TestNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache("loader", time.Minute, 0, 0)
+	defer c.Close()
+	l := New(c, WithNegativeExpiration(time.Minute))
+
+	var calls int
+	load := func() (interface{}, error) {
+		calls++
+		return nil, fmt.Errorf("device d1: %w", ErrNotFound)
+	}
+	var v string
+	assert.True(t, errors.Is(l.GetOrLoad(ctx, "k", &v, load), ErrNotFound))
+	assert.Equal(t, cache.ErrNotFound, l.GetOrLoad(ctx, "k", &v, load))
+	assert.Equal(t, 1, calls)
+}
+
+func TestLoadPanic(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache("loader", time.Minute, 0, 0)
+	defer c.Close()
+	l := New(c, WithLease(time.Minute, time.Millisecond))
+
+	var v string
+	err := l.GetOrLoad(ctx, "k", &v, func() (interface{}, error) {
+		panic("boom")
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "boom")
+	}
+	// panic 后租约已释放, 再次加载不需要等待
+	assert.NoError(t, l.GetOrLoad(ctx, "k", &v, func() (interface{}, error) {
+		return "value", nil
+	}))
+	assert.Equal(t, "value", v)
+}
+
+func TestLeaseRelease(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache("loader", time.Minute, 0, 0)
+	defer c.Close()
+	l := New(c, WithLease(time.Minute, time.Millisecond))
+
+	var v string
+	assert.NoError(t, l.GetOrLoad(ctx, "k", &v, func() (interface{}, error) {
+		// 模拟加载超过租约时间, 租约被其他实例获取
+		assert.NoError(t, c.Set(ctx, "k"+leaseSuffix, "other"))
+		return "value", nil
+	}))
+	assert.Equal(t, "value", v)
+	var owner string
+	exist, err := c.Get(ctx, "k"+leaseSuffix, &owner)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, "other", owner)
+
+	assert.NoError(t, l.GetOrLoad(ctx, "k2", &v, func() (interface{}, error) {
+		return "value", nil
+	}))
+	exist, err = c.Get(ctx, "k2"+leaseSuffix, &owner)
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
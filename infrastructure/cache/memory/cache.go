@@ -0,0 +1,469 @@
+package memory
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 即 cache.ErrNotFound
+var ErrNotFound = cache.ErrNotFound
+
+type entry struct {
+	key        string
+	value      []byte
+	expiration int64 // UnixNano, 0 表示永不过期
+	tags       []string
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.expiration > 0 && now > e.expiration
+}
+
+// Cache 进程内缓存, 语义与 redis.Cache 保持一致, 超过 maxEntries 时按 LRU 淘汰
+type Cache struct {
+	mu                sync.Mutex
+	items             map[string]*list.Element
+	ll                *list.List
+	tags              map[string]map[string]struct{} // tag -> 带分组前缀的键
+	group             string
+	defaultExpiration time.Duration
+	maxEntries        int
+	stop              chan struct{}
+	stopOnce          sync.Once
+}
+
+func (c *Cache) key(key string) string {
+	if c.group != "" {
+		return c.group + ":" + key
+	}
+	return key
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithExpiration(ctx, key, value, c.defaultExpiration)
+}
+
+func (c *Cache) SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	payload, err := encode(value)
+	if err != nil {
+		return err
+	}
+	c.set(c.key(key), payload, expiration)
+	return nil
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	payload, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	k := c.key(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[k]; ok && !element.Value.(*entry).expired(time.Now().UnixNano()) {
+		return false, nil
+	}
+	c.setLocked(k, payload, expiration)
+	return true, nil
+}
+
+func (c *Cache) CompareAndDelete(ctx context.Context, key string, value interface{}) (bool, error) {
+	payload, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	k := c.key(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(k)
+	if !ok || !bytes.Equal(e.value, payload) {
+		return false, nil
+	}
+	c.removeElement(c.items[k])
+	return true, nil
+}
+
+func (c *Cache) Get(ctx context.Context, key string, to interface{}) (exist bool, err error) {
+	payload, ok := c.get(c.key(key))
+	if !ok {
+		return false, nil
+	}
+	err = decode(payload, to)
+	if err == nil {
+		return true, nil
+	}
+	return true, err
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	k := c.key(key)
+	c.mu.Lock()
+	if element, ok := c.items[k]; ok {
+		c.removeElement(element)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	return c.SetBytesWithExpiration(ctx, key, value, c.defaultExpiration)
+}
+
+func (c *Cache) SetBytesWithExpiration(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	payload := make([]byte, len(value))
+	copy(payload, value)
+	c.set(c.key(key), payload, expiration)
+	return nil
+}
+
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	payload, ok := c.get(c.key(key))
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := make([]byte, len(payload))
+	copy(result, payload)
+	return result, nil
+}
+
+func (c *Cache) GetDefaultExpiration() time.Duration {
+	return c.defaultExpiration
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := c.key(key)
+	e, ok := c.lookup(k)
+	if !ok {
+		return false, nil
+	}
+	if expiration <= 0 { // 与 redis 一致, 非正数过期时间直接删除
+		c.removeElement(c.items[k])
+		return true, nil
+	}
+	e.expiration = expireAt(expiration)
+	return true, nil
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(c.key(key))
+	if !ok || e.expiration == 0 {
+		return false, nil
+	}
+	e.expiration = 0
+	return true, nil
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (ttl time.Duration, exist bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(c.key(key))
+	if !ok {
+		return 0, false, nil
+	}
+	if e.expiration == 0 {
+		return cache.NoExpiration, true, nil
+	}
+	return time.Duration(e.expiration - time.Now().UnixNano()), true, nil
+}
+
+func (c *Cache) MGet(ctx context.Context, keys []string, to []interface{}) (exist []bool, err error) {
+	if len(keys) != len(to) {
+		return nil, errors.New("keys and to length mismatch")
+	}
+	exist = make([]bool, len(keys))
+	for i, key := range keys {
+		if exist[i], err = c.Get(ctx, key, to[i]); err != nil {
+			return exist, err
+		}
+	}
+	return exist, nil
+}
+
+func (c *Cache) MSet(ctx context.Context, values map[string]interface{}) error {
+	for key, value := range values {
+		if err := c.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) MDelete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		_ = c.Delete(ctx, key)
+	}
+	return nil
+}
+
+func (c *Cache) MGetBytes(ctx context.Context, keys []string) ([][]byte, error) {
+	result := make([][]byte, len(keys))
+	for i, key := range keys {
+		result[i], _ = c.GetBytes(ctx, key)
+	}
+	return result, nil
+}
+
+func (c *Cache) MSetBytes(ctx context.Context, values map[string][]byte) error {
+	for key, value := range values {
+		_ = c.SetBytes(ctx, key, value)
+	}
+	return nil
+}
+
+func (c *Cache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, 1, c.defaultExpiration)
+}
+
+func (c *Cache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, -1, c.defaultExpiration)
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, delta, c.defaultExpiration)
+}
+
+func (c *Cache) IncrByWithExpiration(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	k := c.key(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(k)
+	if !ok {
+		c.setLocked(k, []byte(strconv.FormatInt(delta, 10)), expiration)
+		return delta, nil
+	}
+	n, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, errors.New("cache: value is not an integer")
+	}
+	n += delta
+	e.value = []byte(strconv.FormatInt(n, 10))
+	if e.expiration == 0 {
+		e.expiration = expireAt(expiration)
+	}
+	return n, nil
+}
+
+// Clear 删除分组下的所有键, 与 redis 一致, 未设置分组时返回错误
+func (c *Cache) Clear(ctx context.Context) error {
+	if c.group == "" {
+		return errors.New("cache: Clear requires a group")
+	}
+	return c.DeleteByPrefix(ctx, "")
+}
+
+func (c *Cache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	if c.group == "" && prefix == "" {
+		return errors.New("cache: DeleteByPrefix requires a group or prefix")
+	}
+	c.deleteByPrefix(c.key(prefix))
+	return nil
+}
+
+// Flush 删除所有键, 不区分分组
+func (c *Cache) Flush() {
+	c.deleteByPrefix("")
+}
+
+func (c *Cache) deleteByPrefix(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, element := range c.items {
+		if strings.HasPrefix(key, p) {
+			c.removeElement(element)
+		}
+	}
+}
+
+func (c *Cache) SetWithTags(ctx context.Context, key string, value interface{}, tags ...string) error {
+	payload, err := encode(value)
+	if err != nil {
+		return err
+	}
+	k := c.key(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(k, payload, c.defaultExpiration)
+	e := c.items[k].Value.(*entry)
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			c.tags[tag] = keys
+		}
+		if _, ok = keys[k]; !ok {
+			keys[k] = struct{}{}
+			e.tags = append(e.tags, tag)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) DeleteByTags(ctx context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if element, ok := c.items[key]; ok {
+				c.removeElement(element)
+			}
+		}
+		delete(c.tags, tag)
+	}
+	return nil
+}
+
+// Len 返回当前缓存条目数(包含尚未被清理的过期条目)
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Close 停止后台清理协程
+func (c *Cache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *Cache) set(key string, payload []byte, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, payload, expiration)
+}
+
+func (c *Cache) setLocked(key string, payload []byte, expiration time.Duration) {
+	exp := expireAt(expiration)
+	if element, ok := c.items[key]; ok {
+		c.ll.MoveToFront(element)
+		e := element.Value.(*entry)
+		e.value = payload
+		e.expiration = exp
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: payload, expiration: exp})
+	if c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// lookup 查找未过期的条目并标记为最近使用, 调用方需持有锁
+func (c *Cache) lookup(key string) (*entry, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if e.expired(time.Now().UnixNano()) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.ll.MoveToFront(element)
+	return e, true
+}
+
+func (c *Cache) removeElement(element *list.Element) {
+	c.ll.Remove(element)
+	e := element.Value.(*entry)
+	delete(c.items, e.key)
+	for _, tag := range e.tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, e.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
+
+// deleteExpired 删除所有已过期条目
+func (c *Cache) deleteExpired() {
+	now := time.Now().UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for element := c.ll.Back(); element != nil; {
+		prev := element.Prev()
+		if element.Value.(*entry).expired(now) {
+			c.removeElement(element)
+		}
+		element = prev
+	}
+}
+
+func (c *Cache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.deleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func expireAt(expiration time.Duration) int64 {
+	if expiration > 0 {
+		return time.Now().Add(expiration).UnixNano()
+	}
+	return 0
+}
+
+// NewCache 创建进程内缓存
+// maxEntries <= 0 时不限制条目数; cleanupInterval > 0 时启动后台协程定期清理过期条目, 不再使用时需调用 Close
+func NewCache(group string, defaultExpiration time.Duration, maxEntries int, cleanupInterval time.Duration) *Cache {
+	c := &Cache{
+		items:             map[string]*list.Element{},
+		ll:                list.New(),
+		tags:              map[string]map[string]struct{}{},
+		group:             group,
+		defaultExpiration: defaultExpiration,
+		maxEntries:        maxEntries,
+		stop:              make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go c.janitor(cleanupInterval)
+	}
+	return c
+}
+
+// Encode 用gob进行数据编码
+func encode(data interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := gob.NewEncoder(buf)
+	err := enc.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode 用gob进行数据解码
+func decode(data []byte, to interface{}) error {
+	buf := bytes.NewBuffer(data)
+	dec := gob.NewDecoder(buf)
+	return dec.Decode(to)
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache("test", time.Minute, 0, 0)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "a", "value"))
+	var s string
+	exist, err := c.Get(ctx, "a", &s)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, "value", s)
+
+	assert.NoError(t, c.SetBytes(ctx, "b", []byte{1, 2, 3}))
+	b, err := c.GetBytes(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, b)
+
+	assert.NoError(t, c.Delete(ctx, "a"))
+	exist, err = c.Get(ctx, "a", &s)
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	_, err = c.GetBytes(ctx, "a")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func TestCacheExpiration(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache("", 20*time.Millisecond, 0, 10*time.Millisecond)
+	defer c.Close()
+
+	assert.NoError(t, c.SetBytes(ctx, "a", []byte("a")))
+	time.Sleep(50 * time.Millisecond)
+	_, err := c.GetBytes(ctx, "a")
+	assert.Equal(t, cache.ErrNotFound, err)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache("", 0, 2, 0)
+	defer c.Close()
+
+	assert.NoError(t, c.SetBytes(ctx, "a", []byte("a")))
+	assert.NoError(t, c.SetBytes(ctx, "b", []byte("b")))
+	_, err := c.GetBytes(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetBytes(ctx, "c", []byte("c")))
+
+	assert.Equal(t, 2, c.Len())
+	_, err = c.GetBytes(ctx, "b")
+	assert.Equal(t, cache.ErrNotFound, err)
+	_, err = c.GetBytes(ctx, "a")
+	assert.NoError(t, err)
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache("", time.Minute, 0, 0)
+	defer c.Close()
+
+	ok, err := c.SetNX(ctx, "a", 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.SetNX(ctx, "a", 2, 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ttl, exist, err := c.TTL(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, cache.NoExpiration, ttl)
+
+	ok, err = c.Expire(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ttl, _, _ = c.TTL(ctx, "a")
+	assert.True(t, ttl > time.Minute && ttl <= time.Hour)
+
+	ok, err = c.Persist(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, exist, err = c.TTL(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache("g", 0, 0, 0)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "edge:d1:p1", "int"))
+	assert.NoError(t, c.Set(ctx, "edge:d1:p2", "int"))
+	assert.NoError(t, c.Set(ctx, "edge:d2:p1", "int"))
+	assert.NoError(t, c.DeleteByPrefix(ctx, "edge:d1:"))
+	assert.Equal(t, 1, c.Len())
+
+	assert.NoError(t, c.SetWithTags(ctx, "a", 1, "t1"))
+	assert.NoError(t, c.SetWithTags(ctx, "b", 1, "t1", "t2"))
+	assert.NoError(t, c.DeleteByTags(ctx, "t1"))
+	assert.Equal(t, 1, c.Len())
+	assert.Empty(t, c.tags)
+
+	assert.NoError(t, c.Clear(ctx))
+	assert.Equal(t, 0, c.Len())
+
+	// 与 redis 一致, 未设置分组时不允许清空
+	noGroup := NewCache("", 0, 0, 0)
+	defer noGroup.Close()
+	assert.NoError(t, noGroup.Set(ctx, "a", 1))
+	assert.Error(t, noGroup.Clear(ctx))
+	assert.Error(t, noGroup.DeleteByPrefix(ctx, ""))
+	assert.Equal(t, 1, noGroup.Len())
+	noGroup.Flush()
+	assert.Equal(t, 0, noGroup.Len())
+}
@@ -2,25 +2,127 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	redisBase "github.com/go-redis/redis/v8"
+	"io/ioutil"
 	"strings"
 	"time"
 )
 
+// ReadRouting 只读命令的路由方式, 只对集群和哨兵模式生效
+type ReadRouting string
+
+const (
+	// 只读主节点, 哨兵模式的默认值
+	ReadRoutingMaster ReadRouting = "master"
+	// 只读从节点, 不支持哨兵模式
+	ReadRoutingReplica ReadRouting = "replica"
+	// 随机读取主节点或从节点, 集群模式的默认值
+	ReadRoutingRandom ReadRouting = "random"
+	// 读取延迟最低的节点
+	ReadRoutingLatency ReadRouting = "latency"
+)
+
+type TLSConfig struct {
+	CACert             string // CA 证书文件
+	Cert               string // 客户端证书文件
+	Key                string // 客户端私钥文件
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
 type Config struct {
-	Addr           string
-	Password       string
-	Cluster        bool
-	DB             int
-	MaxIdle        int
-	MaxActive      int
-	IdleTimeout    int // 240s
-	ConnectTimeout int // 10s
-	ReadTimeout    int // 10s
-	WriteTimeout   int // 10s
+	Addr               string // 逗号分隔, 哨兵模式下为哨兵地址
+	Username           string // redis 6 ACL 用户名
+	Password           string
+	Cluster            bool
+	DB                 int // 集群模式下只能为 0
+	MaxIdle            int // 已废弃, go-redis 不限制最大空闲连接数, 只能为 0
+	MinIdle            int // 每个节点保持的最小空闲连接数
+	MaxActive          int
+	IdleTimeout        int // 240s
+	ConnectTimeout     int // 10s
+	ReadTimeout        int // 10s
+	WriteTimeout       int // 10s
+	SentinelMasterName string
+	SentinelPassword   string
+	TLS                *TLSConfig
+	ReadRouting        ReadRouting // 集群模式 random, 哨兵模式 master
+}
+
+func (conf *Config) validate() error {
+	if strings.TrimSpace(conf.Addr) == "" {
+		return errors.New("redis config: Addr is required")
+	}
+	if conf.DB < 0 {
+		return fmt.Errorf("redis config: DB %d is invalid", conf.DB)
+	}
+	if conf.Cluster && conf.DB != 0 {
+		return errors.New("redis config: DB must be 0 in cluster mode")
+	}
+	if conf.Cluster && conf.SentinelMasterName != "" {
+		return errors.New("redis config: SentinelMasterName can not be used in cluster mode")
+	}
+	if conf.SentinelMasterName == "" && conf.SentinelPassword != "" {
+		return errors.New("redis config: SentinelPassword requires SentinelMasterName")
+	}
+	if conf.MaxIdle != 0 {
+		return errors.New("redis config: MaxIdle is not supported, use MinIdle and MaxActive")
+	}
+	if conf.MaxActive < 0 {
+		return fmt.Errorf("redis config: MaxActive %d is invalid", conf.MaxActive)
+	}
+	if conf.MinIdle < 0 || (conf.MaxActive > 0 && conf.MinIdle > conf.MaxActive) {
+		return fmt.Errorf("redis config: MinIdle %d is invalid", conf.MinIdle)
+	}
+	switch conf.ReadRouting {
+	case "", ReadRoutingMaster, ReadRoutingRandom, ReadRoutingLatency:
+	case ReadRoutingReplica:
+		if conf.SentinelMasterName != "" {
+			return errors.New("redis config: ReadRouting replica is not supported in sentinel mode")
+		}
+	default:
+		return fmt.Errorf("redis config: ReadRouting %q is invalid", conf.ReadRouting)
+	}
+	if conf.TLS != nil && (conf.TLS.Cert == "") != (conf.TLS.Key == "") {
+		return errors.New("redis config: TLS.Cert and TLS.Key must be set together")
+	}
+	return nil
+}
+
+func (conf *TLSConfig) clientConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+	if conf.CACert != "" {
+		ca, err := ioutil.ReadFile(conf.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("redis config: TLS.CACert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("redis config: TLS.CACert contains no valid certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.Cert, conf.Key)
+		if err != nil {
+			return nil, fmt.Errorf("redis config: TLS.Cert/TLS.Key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
 
 func NewClient(conf *Config) (redisBase.UniversalClient, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
 	if conf.IdleTimeout <= 0 {
 		conf.IdleTimeout = 240
 	}
@@ -33,25 +135,75 @@ func NewClient(conf *Config) (redisBase.UniversalClient, error) {
 	if conf.WriteTimeout <= 0 {
 		conf.WriteTimeout = 10
 	}
+	if conf.ReadRouting == "" {
+		conf.ReadRouting = ReadRoutingRandom
+		if conf.SentinelMasterName != "" {
+			conf.ReadRouting = ReadRoutingMaster
+		}
+	}
 	idleTimeout := time.Duration(conf.IdleTimeout) * time.Second
 	connectTimeout := time.Duration(conf.ConnectTimeout) * time.Second
 	readTimeout := time.Duration(conf.ReadTimeout) * time.Second
 	writeTimeout := time.Duration(conf.WriteTimeout) * time.Second
 
+	var tlsConfig *tls.Config
+	if conf.TLS != nil {
+		var err error
+		tlsConfig, err = conf.TLS.clientConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	adds := strings.Split(conf.Addr, ",")
-	conn := redisBase.NewUniversalClient(&redisBase.UniversalOptions{
-		Addrs:         adds,
-		ReadOnly:      true,
-		RouteRandomly: true,
-		PoolSize:      conf.MaxActive,
-		Password:      conf.Password,
-		ReadTimeout:   readTimeout,
-		WriteTimeout:  writeTimeout,
-		PoolTimeout:   connectTimeout,
-		IdleTimeout:   idleTimeout,
-	})
+	opts := &redisBase.UniversalOptions{
+		Addrs:            adds,
+		DB:               conf.DB,
+		Username:         conf.Username,
+		Password:         conf.Password,
+		SentinelPassword: conf.SentinelPassword,
+		MasterName:       conf.SentinelMasterName,
+		PoolSize:         conf.MaxActive,
+		MinIdleConns:     conf.MinIdle,
+		DialTimeout:      connectTimeout,
+		ReadTimeout:      readTimeout,
+		WriteTimeout:     writeTimeout,
+		PoolTimeout:      connectTimeout,
+		IdleTimeout:      idleTimeout,
+		TLSConfig:        tlsConfig,
+	}
+	var conn redisBase.UniversalClient
+	switch {
+	case conf.SentinelMasterName != "":
+		failoverOpts := opts.Failover()
+		switch conf.ReadRouting {
+		case ReadRoutingRandom:
+			failoverOpts.RouteRandomly = true
+			conn = redisBase.NewFailoverClusterClient(failoverOpts)
+		case ReadRoutingLatency:
+			failoverOpts.RouteByLatency = true
+			conn = redisBase.NewFailoverClusterClient(failoverOpts)
+		default:
+			conn = redisBase.NewFailoverClient(failoverOpts)
+		}
+	case conf.Cluster || len(adds) > 1:
+		clusterOpts := opts.Cluster()
+		switch conf.ReadRouting {
+		case ReadRoutingReplica:
+			clusterOpts.ReadOnly = true
+		case ReadRoutingRandom:
+			clusterOpts.ReadOnly = true
+			clusterOpts.RouteRandomly = true
+		case ReadRoutingLatency:
+			clusterOpts.RouteByLatency = true
+		}
+		conn = redisBase.NewClusterClient(clusterOpts)
+	default:
+		conn = redisBase.NewClient(opts.Simple())
+	}
 	_, err := conn.Ping(context.TODO()).Result()
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
 	return conn, err
@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		conf  Config
+		valid bool
+	}{
+		{"single", Config{Addr: "127.0.0.1:6379"}, true},
+		{"empty addr", Config{Addr: " "}, false},
+		{"negative db", Config{Addr: "a:6379", DB: -1}, false},
+		{"cluster db", Config{Addr: "a:6379", Cluster: true, DB: 1}, false},
+		{"cluster sentinel", Config{Addr: "a:6379", Cluster: true, SentinelMasterName: "m"}, false},
+		{"sentinel password without master", Config{Addr: "a:26379", SentinelPassword: "p"}, false},
+		{"sentinel", Config{Addr: "a:26379", SentinelMasterName: "m", SentinelPassword: "p"}, true},
+		{"negative max active", Config{Addr: "a:6379", MaxActive: -1}, false},
+		{"negative min idle", Config{Addr: "a:6379", MinIdle: -1}, false},
+		{"min idle over max active", Config{Addr: "a:6379", MaxActive: 5, MinIdle: 6}, false},
+		{"min idle", Config{Addr: "a:6379", MaxActive: 10, MinIdle: 2}, true},
+		{"max idle", Config{Addr: "a:6379", MaxIdle: 100}, false},
+		{"replica routing", Config{Addr: "a:6379,b:6379", ReadRouting: ReadRoutingReplica}, true},
+		{"replica routing sentinel", Config{Addr: "a:26379", SentinelMasterName: "m", ReadRouting: ReadRoutingReplica}, false},
+		{"unknown routing", Config{Addr: "a:6379", ReadRouting: "nearest"}, false},
+		{"tls cert without key", Config{Addr: "a:6379", TLS: &TLSConfig{Cert: "client.pem"}}, false},
+		{"tls", Config{Addr: "a:6379", TLS: &TLSConfig{Cert: "client.pem", Key: "client-key.pem"}}, true},
+	} {
+		err := c.conf.validate()
+		if c.valid {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.Error(t, err, c.name)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package redis
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"errors"
 	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"github.com/huskar-t/gopher/infrastructure/cache/codec"
 	"time"
 )
 
@@ -12,6 +13,24 @@ type Cache struct {
 	conn              redisBase.UniversalClient
 	group             string
 	defaultExpiration time.Duration
+	codec             codec.Codec
+	legacyCodec       codec.Codec
+}
+
+type Option func(c *Cache)
+
+// WithCodec 设置写入时使用的编码器, 默认为 gob
+func WithCodec(c codec.Codec) Option {
+	return func(cache *Cache) {
+		cache.codec = c
+	}
+}
+
+// WithLegacyCodec 设置没有编码头部的旧数据使用的解码器, 默认为 gob
+func WithLegacyCodec(c codec.Codec) Option {
+	return func(cache *Cache) {
+		cache.legacyCodec = c
+	}
 }
 
 func (c *Cache) key(key string) string {
@@ -21,12 +40,42 @@ func (c *Cache) key(key string) string {
 	return key
 }
 func (c *Cache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithExpiration(ctx, key, value, c.defaultExpiration)
+}
+
+func (c *Cache) SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	k := c.key(key)
-	payload, err := encode(value)
+	payload, err := c.encode(value)
 	if err != nil {
 		return err
 	}
-	return c.conn.Set(ctx, k, payload, c.defaultExpiration).Err()
+	return c.conn.Set(ctx, k, payload, expiration).Err()
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	k := c.key(key)
+	payload, err := c.encode(value)
+	if err != nil {
+		return false, err
+	}
+	return c.conn.SetNX(ctx, k, payload, expiration).Result()
+}
+
+// compareAndDeleteScript 值相等时删除
+var compareAndDeleteScript = redisBase.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (c *Cache) CompareAndDelete(ctx context.Context, key string, value interface{}) (bool, error) {
+	payload, err := c.encode(value)
+	if err != nil {
+		return false, err
+	}
+	n, err := compareAndDeleteScript.Run(ctx, c.conn, []string{c.key(key)}, payload).Int64()
+	return n > 0, err
 }
 
 func (c *Cache) Get(ctx context.Context, key string, to interface{}) (exist bool, err error) {
@@ -38,7 +87,7 @@ func (c *Cache) Get(ctx context.Context, key string, to interface{}) (exist bool
 		}
 		return false, err
 	}
-	err = decode(payload, to)
+	err = c.decode(payload, to)
 	if err == nil {
 		return true, nil
 	}
@@ -50,38 +99,206 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	return c.conn.Del(ctx, k).Err()
 }
 func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	return c.SetBytesWithExpiration(ctx, key, value, c.defaultExpiration)
+}
+func (c *Cache) SetBytesWithExpiration(ctx context.Context, key string, value []byte, expiration time.Duration) error {
 	k := c.key(key)
-	return c.conn.Set(ctx, k, value, c.defaultExpiration).Err()
+	return c.conn.Set(ctx, k, value, expiration).Err()
 }
 func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
 	k := c.key(key)
-	return c.conn.Get(ctx, k).Bytes()
+	payload, err := c.conn.Get(ctx, k).Bytes()
+	if err == redisBase.Nil {
+		return nil, cache.ErrNotFound
+	}
+	return payload, err
 }
 func (c *Cache) GetDefaultExpiration() time.Duration {
 	return c.defaultExpiration
 }
-func NewCache(client redisBase.UniversalClient, group string, defaultExpiration time.Duration) *Cache {
-	return &Cache{
-		group:             group,
-		defaultExpiration: defaultExpiration,
-		conn:              client,
+func (c *Cache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	k := c.key(key)
+	return c.conn.Expire(ctx, k, expiration).Result()
+}
+func (c *Cache) Persist(ctx context.Context, key string) (bool, error) {
+	k := c.key(key)
+	return c.conn.Persist(ctx, k).Result()
+}
+func (c *Cache) TTL(ctx context.Context, key string) (ttl time.Duration, exist bool, err error) {
+	k := c.key(key)
+	ttl, err = c.conn.PTTL(ctx, k).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	switch ttl {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return cache.NoExpiration, true, nil
 	}
+	return ttl, true, nil
 }
 
-// Encode 用gob进行数据编码
-func encode(data interface{}) ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	enc := gob.NewEncoder(buf)
-	err := enc.Encode(data)
+// MGet 使用 pipeline 逐个 GET, 集群模式下键可以分布在不同的 slot
+func (c *Cache) MGet(ctx context.Context, keys []string, to []interface{}) (exist []bool, err error) {
+	if len(keys) != len(to) {
+		return nil, errors.New("keys and to length mismatch")
+	}
+	payloads, err := c.MGetBytes(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	exist = make([]bool, len(keys))
+	for i, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		exist[i] = true
+		if err = c.decode(payload, to[i]); err != nil {
+			return exist, err
+		}
+	}
+	return exist, nil
+}
+
+func (c *Cache) MSet(ctx context.Context, values map[string]interface{}) error {
+	payloads := make(map[string][]byte, len(values))
+	for key, value := range values {
+		payload, err := c.encode(value)
+		if err != nil {
+			return err
+		}
+		payloads[key] = payload
+	}
+	return c.MSetBytes(ctx, payloads)
+}
+
+func (c *Cache) MDelete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.conn.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, c.key(key))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *Cache) MGetBytes(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := c.conn.Pipeline()
+	cmds := make([]*redisBase.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, c.key(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redisBase.Nil {
+		return nil, err
+	}
+	result := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		payload, err := cmd.Bytes()
+		if err != nil {
+			if err == redisBase.Nil {
+				continue
+			}
+			return nil, err
+		}
+		result[i] = payload
+	}
+	return result, nil
+}
+
+// mgetWithTTL 与 MGetBytes 一样读取键的值, 同一个 pipeline 中读取剩余过期时间
+// 剩余过期时间与 PTTL 相同, -1 为永不过期, -2 为读取 PTTL 前键已过期
+func (c *Cache) mgetWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, error) {
+	pipe := c.conn.Pipeline()
+	gets := make([]*redisBase.StringCmd, len(keys))
+	pttls := make([]*redisBase.DurationCmd, len(keys))
+	for i, key := range keys {
+		k := c.key(key)
+		gets[i] = pipe.Get(ctx, k)
+		pttls[i] = pipe.PTTL(ctx, k)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redisBase.Nil {
+		return nil, nil, err
+	}
+	payloads := make([][]byte, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	for i := range keys {
+		payload, err := gets[i].Bytes()
+		if err != nil {
+			if err == redisBase.Nil {
+				continue
+			}
+			return nil, nil, err
+		}
+		payloads[i] = payload
+		ttls[i] = pttls[i].Val()
+	}
+	return payloads, ttls, nil
+}
+
+func (c *Cache) MSetBytes(ctx context.Context, values map[string][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	pipe := c.conn.Pipeline()
+	for key, value := range values {
+		pipe.Set(ctx, c.key(key), value, c.defaultExpiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// incrScript 增加计数, 没有过期时间时设置过期时间
+var incrScript = redisBase.NewScript(`
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v
+`)
+
+func (c *Cache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, 1, c.defaultExpiration)
+}
+
+func (c *Cache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, -1, c.defaultExpiration)
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, delta, c.defaultExpiration)
+}
+
+func (c *Cache) IncrByWithExpiration(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	k := c.key(key)
+	return incrScript.Run(ctx, c.conn, []string{k}, delta, expiration.Milliseconds()).Int64()
+}
+
+func NewCache(client redisBase.UniversalClient, group string, defaultExpiration time.Duration, opts ...Option) *Cache {
+	c := &Cache{
+		group:             group,
+		defaultExpiration: defaultExpiration,
+		conn:              client,
+		codec:             codec.Gob,
+		legacyCodec:       codec.Gob,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// encode 使用配置的编码器编码, 数据头部记录编码器 ID
+func (c *Cache) encode(data interface{}) ([]byte, error) {
+	return codec.Encode(c.codec, data)
 }
 
-// Decode 用gob进行数据解码
-func decode(data []byte, to interface{}) error {
-	buf := bytes.NewBuffer(data)
-	dec := gob.NewDecoder(buf)
-	return dec.Decode(to)
+// decode 根据数据头部选择解码器, 兼容任意已注册编码器写入的数据
+func (c *Cache) decode(data []byte, to interface{}) error {
+	return codec.Decode(data, to, c.legacyCodec)
 }
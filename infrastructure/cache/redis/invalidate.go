@@ -0,0 +1,185 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	redisBase "github.com/go-redis/redis/v8"
+	"strings"
+)
+
+const (
+	scanCount = 500
+	tagPrefix = "__tag:"
+	// 每次写入标签时随机检查的成员数
+	pruneCount = 10
+)
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Clear 删除分组下的所有键, 未设置分组时返回错误, 避免误删整个库
+func (c *Cache) Clear(ctx context.Context) error {
+	if c.group == "" {
+		return errors.New("cache: Clear requires a group")
+	}
+	return c.deleteByPattern(ctx, patternEscaper.Replace(c.group)+":*")
+}
+
+func (c *Cache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	if c.group == "" && prefix == "" {
+		return errors.New("cache: DeleteByPrefix requires a group or prefix")
+	}
+	return c.deleteByPattern(ctx, patternEscaper.Replace(c.key(prefix))+"*")
+}
+
+// deleteByPattern 使用 SCAN 遍历删除, 集群模式下遍历所有主节点
+func (c *Cache) deleteByPattern(ctx context.Context, pattern string) error {
+	if cluster, ok := c.conn.(*redisBase.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, master *redisBase.Client) error {
+			return scanDelete(ctx, master, pattern)
+		})
+	}
+	return scanDelete(ctx, c.conn, pattern)
+}
+
+func scanDelete(ctx context.Context, client redisBase.UniversalClient, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			// 同一节点上的键也可能属于不同 slot, 逐个删除避免 CROSSSLOT
+			pipe := client.Pipeline()
+			for _, key := range keys {
+				pipe.Unlink(ctx, key)
+			}
+			if _, err = pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (c *Cache) tagKey(tag string) string {
+	return c.key(tagPrefix + tag)
+}
+
+// SetWithTags 写入并把键加入标签集合, 标签集合的过期时间随每次写入延长
+// 同时随机检查标签集合中的成员, 移除键已过期或被删除的成员, 避免集合无限增长
+func (c *Cache) SetWithTags(ctx context.Context, key string, value interface{}, tags ...string) error {
+	payload, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	pipe := c.conn.Pipeline()
+	pipe.Set(ctx, c.key(key), payload, c.defaultExpiration)
+	for _, tag := range tags {
+		pipe.SAdd(ctx, c.tagKey(tag), key)
+		if c.defaultExpiration > 0 {
+			pipe.PExpire(ctx, c.tagKey(tag), c.defaultExpiration)
+		}
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return err
+	}
+	return c.pruneTags(ctx, tags)
+}
+
+type tagMember struct {
+	tag    string
+	member string
+}
+
+// pruneTags 从每个标签集合中随机取 pruneCount 个成员, 移除键已不存在的成员
+// 移除后再次检查, 期间被重新写入的键加回集合
+func (c *Cache) pruneTags(ctx context.Context, tags []string) error {
+	pipe := c.conn.Pipeline()
+	samples := make([]*redisBase.StringSliceCmd, len(tags))
+	for i, tag := range tags {
+		samples[i] = pipe.SRandMemberN(ctx, c.tagKey(tag), pruneCount)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	var members []tagMember
+	var exists []*redisBase.IntCmd
+	for i, cmd := range samples {
+		for _, member := range cmd.Val() {
+			members = append(members, tagMember{tag: tags[i], member: member})
+			exists = append(exists, pipe.Exists(ctx, c.key(member)))
+		}
+	}
+	if len(exists) == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	var stale []tagMember
+	for i, cmd := range exists {
+		if cmd.Val() == 0 {
+			stale = append(stale, members[i])
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	for _, m := range stale {
+		pipe.SRem(ctx, c.tagKey(m.tag), m.member)
+	}
+	exists = exists[:0]
+	for _, m := range stale {
+		exists = append(exists, pipe.Exists(ctx, c.key(m.member)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	var restored bool
+	for i, cmd := range exists {
+		if cmd.Val() == 1 {
+			pipe.SAdd(ctx, c.tagKey(stale[i].tag), stale[i].member)
+			if c.defaultExpiration > 0 {
+				pipe.PExpire(ctx, c.tagKey(stale[i].tag), c.defaultExpiration)
+			}
+			restored = true
+		}
+	}
+	if !restored {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *Cache) DeleteByTags(ctx context.Context, tags ...string) error {
+	_, err := c.deleteByTags(ctx, tags)
+	return err
+}
+
+// deleteByTags 删除标签下的所有键和标签集合, 返回被删除的键(不含分组前缀)
+func (c *Cache) deleteByTags(ctx context.Context, tags []string) ([]string, error) {
+	var keys []string
+	for _, tag := range tags {
+		members, err := c.conn.SMembers(ctx, c.tagKey(tag)).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, members...)
+	}
+	pipe := c.conn.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, c.key(key))
+	}
+	for _, tag := range tags {
+		pipe.Del(ctx, c.tagKey(tag))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
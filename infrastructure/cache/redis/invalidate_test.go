@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+	s, err := miniredis.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close()
+	client := redisBase.NewClient(&redisBase.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	c := NewCache(client, "g", 0)
+	assert.NoError(t, c.SetWithTags(ctx, "k1", 1, "t"))
+	assert.NoError(t, c.SetWithTags(ctx, "k2", 2, "t"))
+	assert.NoError(t, c.Delete(ctx, "k1"))
+	// 写入时移除键已被删除的成员
+	assert.NoError(t, c.SetWithTags(ctx, "k3", 3, "t"))
+	members, err := s.Members("g:__tag:t")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k2", "k3"}, members)
+	assert.Equal(t, time.Duration(0), s.TTL("g:__tag:t"))
+
+	assert.NoError(t, c.DeleteByTags(ctx, "t"))
+	assert.False(t, s.Exists("g:k2"))
+	assert.False(t, s.Exists("g:k3"))
+	assert.False(t, s.Exists("g:__tag:t"))
+
+	// 有默认过期时间时标签集合随写入延长, 过期的键同样被移除
+	c = NewCache(client, "e", time.Minute)
+	assert.NoError(t, c.SetWithTags(ctx, "k1", 1, "t"))
+	s.FastForward(30 * time.Second)
+	assert.NoError(t, c.SetWithTags(ctx, "k2", 2, "t"))
+	assert.Equal(t, time.Minute, s.TTL("e:__tag:t"))
+	s.FastForward(40 * time.Second)
+	assert.NoError(t, c.SetWithTags(ctx, "k3", 3, "t"))
+	members, err = s.Members("e:__tag:t")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k2", "k3"}, members)
+}
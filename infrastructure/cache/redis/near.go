@@ -0,0 +1,457 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"github.com/huskar-t/gopher/infrastructure/cache/memory"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	invalidateChannelPrefix = "__gopher_near_cache:"
+	generationStripes       = 64
+)
+
+// NearCacheStats 本地缓存命中统计
+type NearCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	LocalSize int
+}
+
+// NearCache 两级缓存, 本地进程内缓存 + redis
+// 写入和删除时通过 redis pub/sub 广播失效消息, 其他实例收到后删除本地缓存
+type NearCache struct {
+	hits    uint64
+	misses  uint64
+	remote  *Cache
+	local   *memory.Cache
+	id      string
+	channel string
+	pubsub  *redisBase.PubSub
+	// 本地缓存每次修改或失效时递增, 从 redis 回填前后不一致时放弃回填
+	generations [generationStripes]uint64
+	flushes     uint64
+}
+
+// NewNearCache 创建两级缓存
+// maxLocalSize 为本地缓存最大条目数; localExpiration 为本地缓存过期时间, <= 0 时使用 remote 的默认过期时间, 两者都不大于 0 时返回错误
+// pub/sub 重连后清空本地缓存, 本地过期时间决定了失效消息丢失时最长的不一致时间
+func NewNearCache(remote *Cache, maxLocalSize int, localExpiration time.Duration) (*NearCache, error) {
+	if localExpiration <= 0 {
+		localExpiration = remote.defaultExpiration
+	}
+	if localExpiration <= 0 {
+		return nil, errors.New("cache: near cache requires a positive local expiration")
+	}
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	c := &NearCache{
+		remote:  remote,
+		local:   memory.NewCache("", localExpiration, maxLocalSize, time.Minute),
+		id:      id,
+		channel: invalidateChannelPrefix + remote.group,
+	}
+	c.pubsub = remote.conn.Subscribe(context.Background(), c.channel)
+	if _, err = c.pubsub.Receive(context.Background()); err != nil {
+		_ = c.pubsub.Close()
+		c.local.Close()
+		return nil, err
+	}
+	go c.listen(c.pubsub.ChannelWithSubscriptions(context.Background(), 100))
+	return c, nil
+}
+
+// 失效消息格式为 "实例ID:操作:参数", 操作 k 删除单个键, p 删除前缀
+const (
+	invalidateKey    = "k"
+	invalidatePrefix = "p"
+)
+
+// listen 处理失效消息, 重新订阅说明连接断开过, 期间的失效消息已丢失, 清空本地缓存
+func (c *NearCache) listen(ch <-chan interface{}) {
+	for received := range ch {
+		switch msg := received.(type) {
+		case *redisBase.Subscription:
+			if msg.Kind == "subscribe" {
+				c.deleteLocalByPrefix("")
+			}
+		case *redisBase.Message:
+			parts := strings.SplitN(msg.Payload, ":", 3)
+			if len(parts) != 3 || parts[0] == c.id {
+				continue
+			}
+			switch parts[1] {
+			case invalidateKey:
+				c.deleteLocal(parts[2])
+			case invalidatePrefix:
+				c.deleteLocalByPrefix(parts[2])
+			}
+		}
+	}
+}
+
+func (c *NearCache) stripe(key string) *uint64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &c.generations[h.Sum32()%generationStripes]
+}
+
+// generation 键所在分段和全部清空的修改次数之和
+func (c *NearCache) generation(key string) uint64 {
+	return atomic.LoadUint64(c.stripe(key)) + atomic.LoadUint64(&c.flushes)
+}
+
+// setLocal 和 deleteLocal 先递增修改次数再修改本地缓存, 使并发的回填能够发现冲突
+func (c *NearCache) setLocal(key string, payload []byte, expiration time.Duration) {
+	atomic.AddUint64(c.stripe(key), 1)
+	_ = c.local.SetBytesWithExpiration(context.Background(), key, payload, expiration)
+}
+
+func (c *NearCache) deleteLocal(key string) {
+	atomic.AddUint64(c.stripe(key), 1)
+	_ = c.local.Delete(context.Background(), key)
+}
+
+// fill 把从 redis 读到的值写入本地缓存, ttl 为 redis 中的剩余过期时间, generation 为读取 redis 前的修改次数
+// 写入后再次检查, 期间有修改或失效时删除, 避免旧值留在本地缓存
+func (c *NearCache) fill(key string, payload []byte, ttl time.Duration, generation uint64) {
+	if ttl == -2 || c.generation(key) != generation {
+		return
+	}
+	_ = c.local.SetBytesWithExpiration(context.Background(), key, payload, c.localExpiration(ttl))
+	if c.generation(key) != generation {
+		_ = c.local.Delete(context.Background(), key)
+	}
+}
+
+// deleteLocalByPrefix 本地缓存不设置分组, prefix 为空时清空本地缓存
+func (c *NearCache) deleteLocalByPrefix(prefix string) {
+	atomic.AddUint64(&c.flushes, 1)
+	if prefix == "" {
+		c.local.Flush()
+		return
+	}
+	_ = c.local.DeleteByPrefix(context.Background(), prefix)
+}
+
+func (c *NearCache) message(op, arg string) string {
+	return c.id + ":" + op + ":" + arg
+}
+
+func (c *NearCache) invalidate(ctx context.Context, key string) error {
+	return c.remote.conn.Publish(ctx, c.channel, c.message(invalidateKey, key)).Err()
+}
+
+func (c *NearCache) invalidateAll(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.remote.conn.Pipeline()
+	for _, key := range keys {
+		pipe.Publish(ctx, c.channel, c.message(invalidateKey, key))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *NearCache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithExpiration(ctx, key, value, c.remote.defaultExpiration)
+}
+
+func (c *NearCache) SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	payload, err := c.remote.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.SetBytesWithExpiration(ctx, key, payload, expiration)
+}
+
+func (c *NearCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	payload, err := c.remote.encode(value)
+	if err != nil {
+		return false, err
+	}
+	ok, err := c.remote.conn.SetNX(ctx, c.remote.key(key), payload, expiration).Result()
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.setLocal(key, payload, c.localExpiration(expiration))
+	return true, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) CompareAndDelete(ctx context.Context, key string, value interface{}) (bool, error) {
+	ok, err := c.remote.CompareAndDelete(ctx, key, value)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.deleteLocal(key)
+	return true, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) Get(ctx context.Context, key string, to interface{}) (exist bool, err error) {
+	payload, err := c.GetBytes(ctx, key)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	err = c.remote.decode(payload, to)
+	if err == nil {
+		return true, nil
+	}
+	return true, err
+}
+
+func (c *NearCache) Delete(ctx context.Context, key string) error {
+	c.deleteLocal(key)
+	if err := c.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *NearCache) SetBytes(ctx context.Context, key string, value []byte) error {
+	return c.SetBytesWithExpiration(ctx, key, value, c.remote.defaultExpiration)
+}
+
+func (c *NearCache) SetBytesWithExpiration(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := c.remote.SetBytesWithExpiration(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	c.setLocal(key, value, c.localExpiration(expiration))
+	return c.invalidate(ctx, key)
+}
+
+func (c *NearCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	payload, err := c.local.GetBytes(ctx, key)
+	if err == nil {
+		atomic.AddUint64(&c.hits, 1)
+		return payload, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	generation := c.generation(key)
+	payloads, ttls, err := c.remote.mgetWithTTL(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if payloads[0] == nil {
+		return nil, cache.ErrNotFound
+	}
+	c.fill(key, payloads[0], ttls[0], generation)
+	return payloads[0], nil
+}
+
+func (c *NearCache) GetDefaultExpiration() time.Duration {
+	return c.remote.defaultExpiration
+}
+
+func (c *NearCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	ok, err := c.remote.Expire(ctx, key, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.deleteLocal(key)
+	return true, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) Persist(ctx context.Context, key string) (bool, error) {
+	return c.remote.Persist(ctx, key)
+}
+
+func (c *NearCache) TTL(ctx context.Context, key string) (ttl time.Duration, exist bool, err error) {
+	return c.remote.TTL(ctx, key)
+}
+
+func (c *NearCache) MGet(ctx context.Context, keys []string, to []interface{}) (exist []bool, err error) {
+	if len(keys) != len(to) {
+		return nil, errors.New("keys and to length mismatch")
+	}
+	payloads, err := c.MGetBytes(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	exist = make([]bool, len(keys))
+	for i, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		exist[i] = true
+		if err = c.remote.decode(payload, to[i]); err != nil {
+			return exist, err
+		}
+	}
+	return exist, nil
+}
+
+func (c *NearCache) MSet(ctx context.Context, values map[string]interface{}) error {
+	payloads := make(map[string][]byte, len(values))
+	for key, value := range values {
+		payload, err := c.remote.encode(value)
+		if err != nil {
+			return err
+		}
+		payloads[key] = payload
+	}
+	return c.MSetBytes(ctx, payloads)
+}
+
+func (c *NearCache) MDelete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.deleteLocal(key)
+	}
+	if err := c.remote.MDelete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.invalidateAll(ctx, keys)
+}
+
+// MGetBytes 优先读取本地缓存, 未命中的键通过一次 pipeline 从 redis 读取值和剩余过期时间
+func (c *NearCache) MGetBytes(ctx context.Context, keys []string) ([][]byte, error) {
+	result := make([][]byte, len(keys))
+	var missKeys []string
+	var missIndexes []int
+	for i, key := range keys {
+		payload, err := c.local.GetBytes(ctx, key)
+		if err == nil {
+			result[i] = payload
+			continue
+		}
+		missKeys = append(missKeys, key)
+		missIndexes = append(missIndexes, i)
+	}
+	atomic.AddUint64(&c.hits, uint64(len(keys)-len(missKeys)))
+	atomic.AddUint64(&c.misses, uint64(len(missKeys)))
+	if len(missKeys) == 0 {
+		return result, nil
+	}
+	generations := make([]uint64, len(missKeys))
+	for i, key := range missKeys {
+		generations[i] = c.generation(key)
+	}
+	payloads, ttls, err := c.remote.mgetWithTTL(ctx, missKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		result[missIndexes[i]] = payload
+		c.fill(missKeys[i], payload, ttls[i], generations[i])
+	}
+	return result, nil
+}
+
+func (c *NearCache) MSetBytes(ctx context.Context, values map[string][]byte) error {
+	if err := c.remote.MSetBytes(ctx, values); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		c.setLocal(key, value, c.local.GetDefaultExpiration())
+		keys = append(keys, key)
+	}
+	return c.invalidateAll(ctx, keys)
+}
+
+func (c *NearCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, 1, c.remote.defaultExpiration)
+}
+
+func (c *NearCache) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, -1, c.remote.defaultExpiration)
+}
+
+func (c *NearCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.IncrByWithExpiration(ctx, key, delta, c.remote.defaultExpiration)
+}
+
+// IncrByWithExpiration 计数只保存在 redis 中, 本地缓存的旧值会被删除
+func (c *NearCache) IncrByWithExpiration(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	n, err := c.remote.IncrByWithExpiration(ctx, key, delta, expiration)
+	if err != nil {
+		return 0, err
+	}
+	c.deleteLocal(key)
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) Clear(ctx context.Context) error {
+	return c.DeleteByPrefix(ctx, "")
+}
+
+func (c *NearCache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	var err error
+	if prefix == "" {
+		err = c.remote.Clear(ctx)
+	} else {
+		err = c.remote.DeleteByPrefix(ctx, prefix)
+	}
+	if err != nil {
+		return err
+	}
+	c.deleteLocalByPrefix(prefix)
+	return c.remote.conn.Publish(ctx, c.channel, c.message(invalidatePrefix, prefix)).Err()
+}
+
+func (c *NearCache) SetWithTags(ctx context.Context, key string, value interface{}, tags ...string) error {
+	if err := c.remote.SetWithTags(ctx, key, value, tags...); err != nil {
+		return err
+	}
+	c.deleteLocal(key)
+	return c.invalidate(ctx, key)
+}
+
+func (c *NearCache) DeleteByTags(ctx context.Context, tags ...string) error {
+	keys, err := c.remote.deleteByTags(ctx, tags)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.deleteLocal(key)
+	}
+	return c.invalidateAll(ctx, keys)
+}
+
+// localExpiration 本地缓存不能比 redis 中的键存活更久
+func (c *NearCache) localExpiration(expiration time.Duration) time.Duration {
+	local := c.local.GetDefaultExpiration()
+	if expiration > 0 && (local <= 0 || expiration < local) {
+		return expiration
+	}
+	return local
+}
+
+// Stats 返回本地缓存命中统计
+func (c *NearCache) Stats() NearCacheStats {
+	return NearCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		LocalSize: c.local.Len(),
+	}
+}
+
+// Close 取消订阅失效消息并停止本地缓存清理
+func (c *NearCache) Close() error {
+	c.local.Close()
+	return c.pubsub.Close()
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
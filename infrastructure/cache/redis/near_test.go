@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestNearCache(t *testing.T, s *miniredis.Miniredis) *NearCache {
+	client := redisBase.NewClient(&redisBase.Options{Addr: s.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	c, err := NewNearCache(NewCache(client, "near", 0), 100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestNearCache(t *testing.T) {
+	ctx := context.Background()
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	a := newTestNearCache(t, s)
+	b := newTestNearCache(t, s)
+
+	// 本地缓存必须有过期时间
+	_, err = NewNearCache(NewCache(nil, "near", 0), 100, 0)
+	assert.Error(t, err)
+
+	// 直接写入 redis, 没有失效消息
+	assert.NoError(t, a.remote.SetBytes(ctx, "k", []byte("v1")))
+	v, err := b.GetBytes(ctx, "k")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	assert.Equal(t, 1, b.Stats().LocalSize)
+
+	// 其他实例写入后本地缓存失效
+	assert.NoError(t, a.SetBytes(ctx, "k", []byte("v2")))
+	assert.Eventually(t, func() bool {
+		v, err = b.GetBytes(ctx, "k")
+		return err == nil && string(v) == "v2"
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = b.GetBytes(ctx, "missing")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func TestNearCacheRemoteTTL(t *testing.T) {
+	ctx := context.Background()
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	a := newTestNearCache(t, s)
+	b := newTestNearCache(t, s)
+
+	// 回填的本地缓存不比 redis 中的键存活更久
+	assert.NoError(t, a.remote.SetBytesWithExpiration(ctx, "short", []byte("v"), 2*time.Second))
+	assert.NoError(t, a.remote.SetBytes(ctx, "forever", []byte("v")))
+	_, err = b.GetBytes(ctx, "short")
+	assert.NoError(t, err)
+	ttl, exist, err := b.local.TTL(ctx, "short")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.True(t, ttl > time.Second && ttl <= 2*time.Second, ttl)
+
+	payloads, err := b.MGetBytes(ctx, []string{"forever", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("v"), nil}, payloads)
+	ttl, exist, err = b.local.TTL(ctx, "forever")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.True(t, ttl > 2*time.Second && ttl <= time.Minute, ttl)
+	assert.Equal(t, 2, b.Stats().LocalSize)
+}
+
+func TestNearCacheFill(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := newTestNearCache(t, s)
+
+	// 读取 redis 期间收到失效消息, 旧值不写入本地缓存
+	generation := c.generation("k")
+	c.deleteLocal("k")
+	c.fill("k", []byte("old"), -1, generation)
+	assert.Equal(t, 0, c.Stats().LocalSize)
+
+	// 读取 PTTL 前键已过期
+	c.fill("k", []byte("old"), -2, c.generation("k"))
+	assert.Equal(t, 0, c.Stats().LocalSize)
+
+	c.fill("k", []byte("v"), -1, c.generation("k"))
+	assert.Equal(t, 1, c.Stats().LocalSize)
+
+	// pub/sub 重连后清空本地缓存
+	s.Close()
+	assert.NoError(t, s.Restart())
+	assert.Eventually(t, func() bool {
+		return c.Stats().LocalSize == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
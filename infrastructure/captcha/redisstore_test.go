@@ -0,0 +1,19 @@
+package captcha
+
+import (
+	"github.com/huskar-t/gopher/infrastructure/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	c := memory.NewCache("captcha", time.Minute, 0, 0)
+	defer c.Close()
+	store := NewStore(c)
+
+	store.Set("id", []byte{1, 2, 3, 4})
+	assert.Equal(t, []byte{1, 2, 3, 4}, store.Get("id", false))
+	assert.Equal(t, []byte{1, 2, 3, 4}, store.Get("id", true))
+	assert.Nil(t, store.Get("id", false))
+}
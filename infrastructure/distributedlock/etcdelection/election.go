@@ -0,0 +1,302 @@
+package etcdelection
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/infrastructure/registry/etcd"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotLeader 当前实例不是领导者
+	ErrNotLeader = errors.New("election: not leader")
+	// ErrNoLeader 当前没有领导者
+	ErrNoLeader = errors.New("election: no leader")
+)
+
+// 竞选失败后的重试间隔
+const retryInterval = time.Second
+
+var closed = make(chan struct{})
+
+func init() {
+	close(closed)
+}
+
+type Option func(e *Election)
+
+// WithTTL 会话租约时间(秒), 领导者失联超过该时间后会被撤销, 默认 60s
+func WithTTL(ttl int) Option {
+	return func(e *Election) {
+		e.ttl = ttl
+	}
+}
+
+// WithOnElected 当选后在新的 goroutine 中执行 fn, ctx 在失去领导权时取消
+func WithOnElected(fn func(ctx context.Context)) Option {
+	return func(e *Election) {
+		e.onElected = fn
+	}
+}
+
+// WithOnRevoked 失去领导权(主动放弃或会话过期)后执行 fn, ctx 为本次任期已取消的 ctx
+func WithOnRevoked(fn func(ctx context.Context)) Option {
+	return func(e *Election) {
+		e.onRevoked = fn
+	}
+}
+
+// Election 基于 etcd 的领导者选举, 同一 prefix 下同一时间只有一个领导者
+// value 为候选者标识, 通过 Leader 和 Observe 对外可见
+type Election struct {
+	client    *clientv3.Client
+	owned     bool
+	prefix    string
+	value     string
+	ttl       int
+	onElected func(ctx context.Context)
+	onRevoked func(ctx context.Context)
+
+	campaignMu sync.Mutex // 串行化 Campaign, 同一实例同一时间只发起一次竞选
+
+	mu      sync.Mutex
+	s       *concurrency.Session
+	e       *concurrency.Election
+	ctx     context.Context // 当前任期, 未当选时为 nil
+	cancel  context.CancelFunc
+	revoked chan struct{}
+}
+
+func New(client *clientv3.Client, prefix, value string, opts ...Option) (*Election, error) {
+	e := &Election{
+		client: client,
+		prefix: prefix,
+		value:  value,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if err := e.session(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Dial 使用与服务注册相同的 TLS 和认证配置连接 etcd, Close 时关闭连接
+func Dial(ctx context.Context, machines []string, options etcd.ClientOptions, prefix, value string, opts ...Option) (*Election, error) {
+	client, err := etcd.NewClientV3(ctx, machines, options)
+	if err != nil {
+		return nil, err
+	}
+	e, err := New(client, prefix, value, opts...)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	e.owned = true
+	return e, nil
+}
+
+// session 会话不存在或已过期时创建新会话, 调用方需持有 mu
+func (e *Election) session() error {
+	if e.s != nil {
+		select {
+		case <-e.s.Done():
+		default:
+			return nil
+		}
+	}
+	var sessionOpts []concurrency.SessionOption
+	if e.ttl > 0 {
+		sessionOpts = append(sessionOpts, concurrency.WithTTL(e.ttl))
+	}
+	s, err := concurrency.NewSession(e.client, sessionOpts...)
+	if err != nil {
+		return err
+	}
+	e.s = s
+	e.e = concurrency.NewElection(s, e.prefix)
+	return nil
+}
+
+// Campaign 阻塞直到当选或 ctx 结束, 已是领导者时直接返回
+// 并发调用时依次执行, 后面的调用在前一次当选后直接返回
+func (e *Election) Campaign(ctx context.Context) error {
+	e.campaignMu.Lock()
+	defer e.campaignMu.Unlock()
+	e.mu.Lock()
+	if e.ctx != nil {
+		e.mu.Unlock()
+		return nil
+	}
+	if err := e.session(); err != nil {
+		e.mu.Unlock()
+		return errors.Wrap(err, "竞选领导者失败")
+	}
+	s, election := e.s, e.e
+	e.mu.Unlock()
+
+	if err := election.Campaign(ctx, e.value); err != nil {
+		return errors.Wrap(err, "竞选领导者失败")
+	}
+
+	e.mu.Lock()
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	revoked := make(chan struct{})
+	e.ctx, e.cancel, e.revoked = leaderCtx, cancel, revoked
+	e.mu.Unlock()
+
+	go func() {
+		select {
+		case <-s.Done():
+			e.revoke(revoked)
+		case <-revoked:
+		}
+	}()
+	if e.onElected != nil {
+		go e.onElected(leaderCtx)
+	}
+	return nil
+}
+
+// revoke 结束 revoked 对应的任期, 已结束时什么都不做
+func (e *Election) revoke(revoked chan struct{}) {
+	e.mu.Lock()
+	if e.revoked != revoked {
+		e.mu.Unlock()
+		return
+	}
+	ctx := e.ctx
+	e.cancel()
+	close(revoked)
+	e.ctx, e.cancel, e.revoked = nil, nil, nil
+	e.mu.Unlock()
+	if e.onRevoked != nil {
+		e.onRevoked(ctx)
+	}
+}
+
+// Resign 放弃领导权, 先取消本次任期的 ctx 再删除领导者键, 之后可以再次竞选
+func (e *Election) Resign(ctx context.Context) error {
+	if !e.IsLeader() {
+		return ErrNotLeader
+	}
+	// 删除领导者键前不允许再次竞选, 当选期间 Campaign 不会长时间持有 campaignMu
+	e.campaignMu.Lock()
+	defer e.campaignMu.Unlock()
+	e.mu.Lock()
+	if e.ctx == nil {
+		e.mu.Unlock()
+		return ErrNotLeader
+	}
+	election, revoked := e.e, e.revoked
+	e.mu.Unlock()
+
+	e.revoke(revoked)
+	if err := election.Resign(ctx); err != nil {
+		return errors.Wrap(err, "放弃领导权失败")
+	}
+	return nil
+}
+
+// IsLeader 当前实例是否为领导者
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ctx != nil
+}
+
+// Done 返回当前任期结束时关闭的通道, 不是领导者时返回已关闭的通道
+func (e *Election) Done() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.revoked == nil {
+		return closed
+	}
+	return e.revoked
+}
+
+// Leader 返回当前领导者的标识, 没有领导者时返回 ErrNoLeader
+func (e *Election) Leader(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	election := e.e
+	e.mu.Unlock()
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		if err == concurrency.ErrElectionNoLeader {
+			return "", ErrNoLeader
+		}
+		return "", err
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Observe 返回领导者标识的变化, 订阅时先推送当前领导者, ctx 结束时关闭
+func (e *Election) Observe(ctx context.Context) <-chan string {
+	e.mu.Lock()
+	election := e.e
+	e.mu.Unlock()
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for resp := range election.Observe(ctx) {
+			if len(resp.Kvs) == 0 {
+				continue
+			}
+			select {
+			case ch <- string(resp.Kvs[0].Value):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Run 持续参与竞选直到 ctx 结束, 失去领导权后重新竞选, 退出前放弃领导权
+// 适合只能运行一个实例的后台任务, 任务本身放在 WithOnElected 中执行
+func (e *Election) Run(ctx context.Context) error {
+	for {
+		if err := e.Campaign(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+		select {
+		case <-e.Done():
+		case <-ctx.Done():
+			_ = e.Resign(context.Background())
+			return ctx.Err()
+		}
+	}
+}
+
+// Close 放弃领导权并关闭会话, 通过 Dial 创建时同时关闭 etcd 连接
+func (e *Election) Close() error {
+	if e.IsLeader() {
+		_ = e.Resign(context.Background())
+	}
+	e.mu.Lock()
+	s := e.s
+	e.s = nil
+	e.mu.Unlock()
+	var err error
+	if s != nil {
+		err = s.Close()
+	}
+	if e.owned {
+		if closeErr := e.client.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+package etcdelection
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/infrastructure/distributedlock/internal/etcdtest"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/client/v3"
+	"sync"
+	"testing"
+	"time"
+)
+
+type candidate struct {
+	*Election
+	elected chan context.Context
+	revoked chan context.Context
+}
+
+func newCandidate(t *testing.T, client *clientv3.Client, value string) *candidate {
+	c := &candidate{
+		elected: make(chan context.Context, 10),
+		revoked: make(chan context.Context, 10),
+	}
+	e, err := New(client, "election", value,
+		WithTTL(5),
+		WithOnElected(func(ctx context.Context) { c.elected <- ctx }),
+		WithOnRevoked(func(ctx context.Context) { c.revoked <- ctx }),
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	c.Election = e
+	return c
+}
+
+func receive(t *testing.T, ch <-chan context.Context) context.Context {
+	t.Helper()
+	select {
+	case ctx := <-ch:
+		return ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not called")
+		return nil
+	}
+}
+
+func observe(t *testing.T, ch <-chan string, want string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case leader := <-ch:
+			if leader == want {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("leader %s not observed", want)
+		}
+	}
+}
+
+func TestCampaign(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	a := newCandidate(t, client, "a")
+	b := newCandidate(t, client, "b")
+
+	_, err := a.Leader(ctx)
+	assert.Equal(t, ErrNoLeader, err)
+	assert.Equal(t, ErrNotLeader, a.Resign(ctx))
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("done not closed before elected")
+	}
+
+	observeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaders := b.Observe(observeCtx)
+
+	assert.NoError(t, a.Campaign(ctx))
+	assert.True(t, a.IsLeader())
+	term := receive(t, a.elected)
+	leader, err := b.Leader(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", leader)
+	observe(t, leaders, "a")
+
+	// 已有领导者时竞选阻塞到 ctx 结束
+	timeout, cancelTimeout := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelTimeout()
+	assert.Error(t, b.Campaign(timeout))
+	assert.False(t, b.IsLeader())
+
+	done := a.Done()
+	assert.NoError(t, a.Resign(ctx))
+	assert.False(t, a.IsLeader())
+	<-done
+	assert.Equal(t, term, receive(t, a.revoked))
+	assert.Error(t, term.Err())
+
+	assert.NoError(t, b.Campaign(ctx))
+	receive(t, b.elected)
+	observe(t, leaders, "b")
+}
+
+func TestConcurrentCampaign(t *testing.T) {
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	a := newCandidate(t, client, "a")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, a.Campaign(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.True(t, a.IsLeader())
+	receive(t, a.elected)
+	assert.Len(t, a.elected, 0)
+}
+
+func TestFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	a := newCandidate(t, client, "a")
+	b := newCandidate(t, client, "b")
+
+	var wg sync.WaitGroup
+	for _, c := range []*candidate{a, b} {
+		wg.Add(1)
+		go func(c *candidate) {
+			defer wg.Done()
+			assert.Equal(t, context.Canceled, c.Run(ctx))
+		}(c)
+	}
+
+	// 先当选的候选者会话租约被撤销后另一个接任, 原领导者重新排队
+	leader, follower := a, b
+	select {
+	case <-a.elected:
+	case <-b.elected:
+		leader, follower = b, a
+	case <-time.After(5 * time.Second):
+		t.Fatal("no candidate elected")
+	}
+	assert.False(t, follower.IsLeader())
+	leader.mu.Lock()
+	lease := leader.s.Lease()
+	leader.mu.Unlock()
+	_, err := client.Revoke(context.Background(), lease)
+	assert.NoError(t, err)
+	receive(t, leader.revoked)
+	receive(t, follower.elected)
+	assert.True(t, follower.IsLeader())
+	assert.False(t, leader.IsLeader())
+	value, err := leader.Leader(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, follower.value, value)
+
+	// 退出时放弃领导权
+	cancel()
+	wg.Wait()
+	receive(t, follower.revoked)
+	_, err = leader.Leader(context.Background())
+	assert.Equal(t, ErrNoLeader, err)
+}
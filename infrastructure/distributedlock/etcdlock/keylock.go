@@ -0,0 +1,169 @@
+package etcdlock
+
+import (
+	"context"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"strings"
+	"sync"
+)
+
+// keyLock 在前缀下创建绑定会话租约的键, 按键的创建版本号排队,
+// 排在前面的等待键少于 limit 个时获得锁, 读写锁和信号量都基于它实现
+type keyLock struct {
+	client *clientv3.Client
+	ttl    int
+
+	mu   sync.Mutex
+	s    *concurrency.Session
+	key  string // 持有时为自己的键
+	lost chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// session 会话不存在或已过期时创建新会话, 调用方需持有 mu
+func (l *keyLock) session() error {
+	if l.s != nil {
+		select {
+		case <-l.s.Done():
+		default:
+			return nil
+		}
+	}
+	s, err := newSession(l.client, l.ttl)
+	if err != nil {
+		return err
+	}
+	l.s = s
+	return nil
+}
+
+func (l *keyLock) acquire(ctx context.Context, pfx, waitPrefix string, limit int64, wait bool) error {
+	err := l.lock(ctx, pfx, waitPrefix, limit, wait)
+	if err == nil || err == distributedlock.ErrLocked {
+		return err
+	}
+	return errors.Wrap(err, "获取分布式锁失败")
+}
+
+// lock 在 pfx 下创建自己的键, 等待 waitPrefix 下创建版本号更小的键少于 limit 个
+func (l *keyLock) lock(ctx context.Context, pfx, waitPrefix string, limit int64, wait bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.key != "" {
+		return errors.New("lock already held")
+	}
+	if err := l.session(); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%x", pfx, l.s.Lease())
+	cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	put := clientv3.OpPut(key, "", clientv3.WithLease(l.s.Lease()))
+	get := clientv3.OpGet(key)
+	resp, err := l.client.Txn(ctx).If(cmp).Then(put).Else(get).Commit()
+	if err != nil {
+		return err
+	}
+	rev := resp.Header.Revision
+	if !resp.Succeeded {
+		rev = resp.Responses[0].GetResponseRange().Kvs[0].CreateRevision
+	}
+	if err = l.wait(ctx, waitPrefix, rev, limit, wait); err != nil {
+		// 放弃排队, 删除自己的键避免阻塞后面的等待者
+		_, _ = l.client.Delete(context.TODO(), key)
+		return err
+	}
+	l.key = key
+	l.lost = make(chan struct{})
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go watch(l.s.Done(), l.lost, l.stop, l.done)
+	return nil
+}
+
+// wait 等待 waitPrefix 下创建版本号小于 rev 的键少于 limit 个, wait 为 false 时不满足立即返回 ErrLocked
+func (l *keyLock) wait(ctx context.Context, waitPrefix string, rev, limit int64, wait bool) error {
+	for {
+		// 版本号过滤不作用于 Count, 只能按返回的键计数
+		resp, err := l.client.Get(ctx, waitPrefix, clientv3.WithPrefix(), clientv3.WithMaxCreateRev(rev-1), clientv3.WithKeysOnly())
+		if err != nil {
+			return err
+		}
+		if int64(len(resp.Kvs)) < limit {
+			return nil
+		}
+		if !wait {
+			return distributedlock.ErrLocked
+		}
+		if err = l.waitDelete(ctx, waitPrefix, resp.Header.Revision); err != nil {
+			return err
+		}
+	}
+}
+
+// waitDelete 等待 prefix 下有键在 rev 之后被删除
+func (l *keyLock) waitDelete(ctx context.Context, prefix string, rev int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wch := l.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1), clientv3.WithFilterPut())
+	for {
+		select {
+		case <-l.s.Done():
+			return concurrency.ErrSessionExpired
+		case resp, ok := <-wch:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return errors.New("etcd watch closed")
+			}
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			if len(resp.Events) > 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// unlock 释放以 pfx 开头的键并关闭会话; 锁已丢失时返回 ErrLockLost
+func (l *keyLock) unlock(pfx string) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.key == "" || !strings.HasPrefix(l.key, pfx) {
+		return distributedlock.ErrNotHeld
+	}
+	key := l.key
+	l.key = ""
+	close(l.stop)
+	<-l.done
+	s := l.s
+	l.s = nil
+	select {
+	case <-l.lost:
+		return ErrLockLost
+	default:
+	}
+
+	_, err = l.client.Delete(context.TODO(), key)
+	if err != nil {
+		_ = s.Close()
+		return
+	}
+	return s.Close()
+}
+
+// Lost 返回当前持有的锁因会话过期丢失时关闭的通道, 未持有锁时返回 nil
+func (l *keyLock) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.key == "" {
+		return nil
+	}
+	return l.lost
+}
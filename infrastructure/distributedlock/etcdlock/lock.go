@@ -2,42 +2,238 @@ package etcdlock
 
 import (
 	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/huskar-t/gopher/infrastructure/distributedlock/fencing"
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
+	"sync"
 	"time"
 )
 
+// ErrLockLost 持有期间会话租约过期, 锁已被释放
+var ErrLockLost = errors.New("lock lost: session expired")
+
+type options struct {
+	ttl int
+}
+
+type Option func(opts *options)
+
+// WithTTL 会话租约时间(秒), 网络分区超过该时间后锁会被释放, 默认 60s
+func WithTTL(ttl int) Option {
+	return func(opts *options) {
+		opts.ttl = ttl
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func newSession(client *clientv3.Client, ttl int) (*concurrency.Session, error) {
+	var sessionOpts []concurrency.SessionOption
+	if ttl > 0 {
+		sessionOpts = append(sessionOpts, concurrency.WithTTL(ttl))
+	}
+	return concurrency.NewSession(client, sessionOpts...)
+}
+
+// EtcdMutex 可重复加锁的分布式锁, 每次加锁使用独立的会话, 解锁时关闭会话释放租约
 type EtcdMutex struct {
-	s *concurrency.Session
-	m *concurrency.Mutex
+	client *clientv3.Client
+	key    string
+	ttl    int
+
+	mu    sync.Mutex
+	s     *concurrency.Session
+	m     *concurrency.Mutex
+	held  bool
+	token fencing.Token
+	lost  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
 }
 
-func NewMutex(key string, client *clientv3.Client) (mutex *EtcdMutex, err error) {
-	mutex = &EtcdMutex{}
-	mutex.s, err = concurrency.NewSession(client)
-	if err != nil {
-		return
+func NewMutex(key string, client *clientv3.Client, opts ...Option) (mutex *EtcdMutex, err error) {
+	mutex = &EtcdMutex{
+		client: client,
+		key:    key,
+		ttl:    newOptions(opts).ttl,
+	}
+	if err = mutex.session(); err != nil {
+		return nil, err
 	}
-	mutex.m = concurrency.NewMutex(mutex.s, key)
 	return
 }
 
+// session 会话不存在或已过期时创建新会话, 调用方需持有 mu
+func (mutex *EtcdMutex) session() error {
+	if mutex.s != nil {
+		select {
+		case <-mutex.s.Done():
+		default:
+			return nil
+		}
+	}
+	s, err := newSession(mutex.client, mutex.ttl)
+	if err != nil {
+		return err
+	}
+	mutex.s = s
+	mutex.m = concurrency.NewMutex(s, mutex.key)
+	return nil
+}
+
 func (mutex *EtcdMutex) Lock() (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
 	defer cancel()
-	if err = mutex.m.Lock(ctx); err != nil {
+	return mutex.LockContext(ctx)
+}
+
+func (mutex *EtcdMutex) LockContext(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, true); err != nil {
 		err = errors.Wrap(err, "获取分布式锁失败")
 	}
 	return
 }
+
+func (mutex *EtcdMutex) TryLock(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, false); err != nil {
+		if err == concurrency.ErrLocked {
+			return distributedlock.ErrLocked
+		}
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *EtcdMutex) lock(ctx context.Context, wait bool) error {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.held {
+		return errors.New("lock already held")
+	}
+	if err := mutex.session(); err != nil {
+		return err
+	}
+	var err error
+	if wait {
+		err = mutex.m.Lock(ctx)
+	} else {
+		err = mutex.m.TryLock(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	// 锁键的创建版本号作为防护令牌, etcd 版本号全局单调递增
+	resp, err := mutex.client.Get(ctx, mutex.m.Key())
+	if err == nil && len(resp.Kvs) == 0 {
+		err = concurrency.ErrSessionExpired
+	}
+	if err != nil {
+		_ = mutex.m.Unlock(context.TODO())
+		return err
+	}
+	mutex.token = fencing.Token(resp.Kvs[0].CreateRevision)
+	mutex.held = true
+	mutex.lost = make(chan struct{})
+	mutex.stop = make(chan struct{})
+	mutex.done = make(chan struct{})
+	go watch(mutex.s.Done(), mutex.lost, mutex.stop, mutex.done)
+	return nil
+}
+
+// LockWithToken 获取锁并返回防护令牌, 写入下游系统时携带令牌以拒绝旧持有者的写入
+func (mutex *EtcdMutex) LockWithToken(ctx context.Context) (fencing.Token, error) {
+	if err := mutex.LockContext(ctx); err != nil {
+		return 0, err
+	}
+	return mutex.Token(), nil
+}
+
+// Token 返回当前持有锁的防护令牌, 未持有锁时返回 0
+func (mutex *EtcdMutex) Token() fencing.Token {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if !mutex.held {
+		return 0
+	}
+	return mutex.token
+}
+
+// watch 会话过期时关闭 lost
+func watch(sessionDone <-chan struct{}, lost, stop, done chan struct{}) {
+	defer close(done)
+	select {
+	case <-sessionDone:
+		close(lost)
+	case <-stop:
+	}
+}
+
+// WithLock 执行 fn 时传入的 ctx 会在锁丢失时取消
+func (mutex *EtcdMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, mutex, cancelOnLost(mutex.Lost, fn))
+}
+
+// cancelOnLost 包装 fn, 锁丢失时取消传给 fn 的 ctx
+func cancelOnLost(lost func() <-chan struct{}, fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		lostCh := lost()
+		go func() {
+			select {
+			case <-lostCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		return fn(ctx)
+	}
+}
+
+// Lost 返回当前持有的锁因会话过期丢失时关闭的通道, 未持有锁时返回 nil
+func (mutex *EtcdMutex) Lost() <-chan struct{} {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if !mutex.held {
+		return nil
+	}
+	return mutex.lost
+}
+
+// Unlock 释放锁并关闭会话, 之后可以再次加锁; 锁已丢失时返回 ErrLockLost
 func (mutex *EtcdMutex) Unlock() (err error) {
-	err = mutex.m.Unlock(context.TODO())
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if !mutex.held {
+		return distributedlock.ErrNotHeld
+	}
+	mutex.held = false
+	mutex.token = 0
+	close(mutex.stop)
+	<-mutex.done
+	select {
+	case <-mutex.lost:
+		mutex.s = nil
+		return ErrLockLost
+	default:
+	}
 
+	err = mutex.m.Unlock(context.TODO())
+	s := mutex.s
+	mutex.s = nil
 	if err != nil {
+		_ = s.Close()
 		return
 	}
-	err = mutex.s.Close()
+	err = s.Close()
 
 	if err != nil {
 		return
@@ -0,0 +1,71 @@
+package etcdlock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/huskar-t/gopher/infrastructure/distributedlock/internal/etcdtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMutex(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	a, err := NewMutex("lock", client, WithTTL(5))
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := NewMutex("lock", client, WithTTL(5))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 解锁后同一实例可以再次加锁, 令牌递增
+	var last int64
+	for i := 0; i < 2; i++ {
+		token, err := a.LockWithToken(ctx)
+		assert.NoError(t, err)
+		assert.True(t, int64(token) > last)
+		last = int64(token)
+		assert.NotNil(t, a.Lost())
+		assert.Equal(t, distributedlock.ErrLocked, b.TryLock(ctx))
+		assert.NoError(t, a.Unlock())
+		assert.Nil(t, a.Lost())
+		assert.Equal(t, distributedlock.ErrNotHeld, a.Unlock())
+	}
+	assert.NoError(t, b.TryLock(ctx))
+	assert.NoError(t, b.Unlock())
+}
+
+func TestMutexLost(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	mutex, err := NewMutex("lock", client, WithTTL(5))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, mutex.Lock())
+	lost := mutex.Lost()
+
+	// 租约被撤销后 Lost 关闭, 锁被释放
+	_, err = client.Revoke(ctx, mutex.s.Lease())
+	assert.NoError(t, err)
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lost not closed")
+	}
+	other, err := NewMutex("lock", client, WithTTL(5))
+	if assert.NoError(t, err) {
+		assert.NoError(t, other.TryLock(ctx))
+		assert.NoError(t, other.Unlock())
+	}
+	assert.Equal(t, ErrLockLost, mutex.Unlock())
+
+	// 丢失锁后同一实例使用新会话继续加锁
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, mutex.Lock())
+		assert.NoError(t, mutex.Unlock())
+	}
+}
@@ -0,0 +1,117 @@
+package etcdlock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"go.etcd.io/etcd/client/v3"
+	"time"
+)
+
+// EtcdRWMutex 分布式读写锁, 多个读者可同时持有, 写者独占
+// 读者和写者按请求顺序排队, 写者不会被后来的读者饿死
+// 每个实例同一时间只能持有一把读锁或写锁, 同一进程内的并发读者需各自创建实例
+type EtcdRWMutex struct {
+	keyLock
+	pfx string
+}
+
+func NewRWMutex(key string, client *clientv3.Client, opts ...Option) (*EtcdRWMutex, error) {
+	rw := &EtcdRWMutex{
+		keyLock: keyLock{
+			client: client,
+			ttl:    newOptions(opts).ttl,
+		},
+		pfx: key + "/",
+	}
+	if err := rw.session(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+func (rw *EtcdRWMutex) readPrefix() string {
+	return rw.pfx + "read/"
+}
+
+func (rw *EtcdRWMutex) writePrefix() string {
+	return rw.pfx + "write/"
+}
+
+// Lock 获取写锁
+func (rw *EtcdRWMutex) Lock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return rw.LockContext(ctx)
+}
+
+// LockContext 获取写锁, 等待排在前面的读者和写者全部释放
+func (rw *EtcdRWMutex) LockContext(ctx context.Context) error {
+	return rw.acquire(ctx, rw.writePrefix(), rw.pfx, 1, true)
+}
+
+func (rw *EtcdRWMutex) TryLock(ctx context.Context) error {
+	return rw.acquire(ctx, rw.writePrefix(), rw.pfx, 1, false)
+}
+
+func (rw *EtcdRWMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, rw, cancelOnLost(rw.Lost, fn))
+}
+
+// Unlock 释放写锁
+func (rw *EtcdRWMutex) Unlock() error {
+	return rw.unlock(rw.writePrefix())
+}
+
+// RLock 获取读锁
+func (rw *EtcdRWMutex) RLock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return rw.RLockContext(ctx)
+}
+
+// RLockContext 获取读锁, 只等待排在前面的写者释放
+func (rw *EtcdRWMutex) RLockContext(ctx context.Context) error {
+	return rw.acquire(ctx, rw.readPrefix(), rw.writePrefix(), 1, true)
+}
+
+func (rw *EtcdRWMutex) TryRLock(ctx context.Context) error {
+	return rw.acquire(ctx, rw.readPrefix(), rw.writePrefix(), 1, false)
+}
+
+func (rw *EtcdRWMutex) WithRLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, rw.RLocker(), cancelOnLost(rw.Lost, fn))
+}
+
+// RUnlock 释放读锁
+func (rw *EtcdRWMutex) RUnlock() error {
+	return rw.unlock(rw.readPrefix())
+}
+
+// RLocker 返回以读锁实现 DistributedLock 的视图
+func (rw *EtcdRWMutex) RLocker() distributedlock.DistributedLock {
+	return rlocker{rw}
+}
+
+type rlocker struct {
+	rw *EtcdRWMutex
+}
+
+func (r rlocker) Lock() error {
+	return r.rw.RLock()
+}
+
+func (r rlocker) Unlock() error {
+	return r.rw.RUnlock()
+}
+
+func (r rlocker) LockContext(ctx context.Context) error {
+	return r.rw.RLockContext(ctx)
+}
+
+func (r rlocker) TryLock(ctx context.Context) error {
+	return r.rw.TryRLock(ctx)
+}
+
+func (r rlocker) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.rw.WithRLock(ctx, fn)
+}
@@ -0,0 +1,83 @@
+package etcdlock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/huskar-t/gopher/infrastructure/distributedlock/internal/etcdtest"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/client/v3"
+	"testing"
+	"time"
+)
+
+func newRWMutexes(t *testing.T, client *clientv3.Client, n int) []*EtcdRWMutex {
+	rws := make([]*EtcdRWMutex, n)
+	for i := range rws {
+		rw, err := NewRWMutex("rw", client, WithTTL(5))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		rws[i] = rw
+	}
+	return rws
+}
+
+func countKeys(t *testing.T, client *clientv3.Client, prefix string) int64 {
+	resp, err := client.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return resp.Count
+}
+
+func TestRWMutexReaders(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	rws := newRWMutexes(t, client, 3)
+
+	// 读者之间互不阻塞
+	assert.NoError(t, rws[0].RLock())
+	assert.NoError(t, rws[1].TryRLock(ctx))
+	assert.Equal(t, distributedlock.ErrLocked, rws[2].TryLock(ctx))
+
+	// 写者等待所有读者释放
+	locked := make(chan error, 1)
+	go func() { locked <- rws[2].Lock() }()
+	assert.NoError(t, rws[0].RUnlock())
+	select {
+	case <-locked:
+		t.Fatal("writer acquired while reader held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	assert.NoError(t, rws[1].RUnlock())
+	select {
+	case err := <-locked:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer not acquired")
+	}
+	assert.NoError(t, rws[2].Unlock())
+}
+
+func TestRWMutexWriter(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	rws := newRWMutexes(t, client, 3)
+
+	// 写者持有时读者和其他写者都被排除
+	assert.NoError(t, rws[0].Lock())
+	assert.Equal(t, distributedlock.ErrLocked, rws[1].TryRLock(ctx))
+	assert.Equal(t, distributedlock.ErrLocked, rws[2].TryLock(ctx))
+
+	// 等待超时后删除排队的键
+	timeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	assert.Error(t, rws[1].RLockContext(timeout))
+	assert.Equal(t, int64(0), countKeys(t, client, rws[1].readPrefix()))
+	assert.Equal(t, int64(1), countKeys(t, client, "rw/"))
+
+	assert.NoError(t, rws[0].Unlock())
+	assert.NoError(t, rws[1].TryRLock(ctx))
+	assert.NoError(t, rws[1].RUnlock())
+	assert.Equal(t, int64(0), countKeys(t, client, "rw/"))
+}
@@ -0,0 +1,60 @@
+package etcdlock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/v3"
+	"time"
+)
+
+// EtcdSemaphore 分布式计数信号量, 最多 limit 个持有者同时获得许可, 按请求顺序排队
+// 同一 key 的所有实例需使用相同的 limit, 每个实例同一时间只持有一个许可
+type EtcdSemaphore struct {
+	keyLock
+	pfx   string
+	limit int64
+}
+
+func NewSemaphore(key string, limit int, client *clientv3.Client, opts ...Option) (*EtcdSemaphore, error) {
+	if limit <= 0 {
+		return nil, errors.New("semaphore limit must be positive")
+	}
+	sem := &EtcdSemaphore{
+		keyLock: keyLock{
+			client: client,
+			ttl:    newOptions(opts).ttl,
+		},
+		pfx:   key + "/",
+		limit: int64(limit),
+	}
+	if err := sem.session(); err != nil {
+		return nil, err
+	}
+	return sem, nil
+}
+
+func (sem *EtcdSemaphore) Lock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return sem.LockContext(ctx)
+}
+
+// LockContext 阻塞直到获得许可或 ctx 结束
+func (sem *EtcdSemaphore) LockContext(ctx context.Context) error {
+	return sem.acquire(ctx, sem.pfx, sem.pfx, sem.limit, true)
+}
+
+// TryLock 许可已用完时立即返回 distributedlock.ErrLocked
+func (sem *EtcdSemaphore) TryLock(ctx context.Context) error {
+	return sem.acquire(ctx, sem.pfx, sem.pfx, sem.limit, false)
+}
+
+func (sem *EtcdSemaphore) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, sem, cancelOnLost(sem.Lost, fn))
+}
+
+// Unlock 归还许可
+func (sem *EtcdSemaphore) Unlock() error {
+	return sem.unlock(sem.pfx)
+}
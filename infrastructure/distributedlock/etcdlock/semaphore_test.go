@@ -0,0 +1,77 @@
+package etcdlock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/huskar-t/gopher/infrastructure/distributedlock/internal/etcdtest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	ctx := context.Background()
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	const limit = 2
+	sems := make([]*EtcdSemaphore, limit+1)
+	for i := range sems {
+		sem, err := NewSemaphore("sem", limit, client, WithTTL(5))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		sems[i] = sem
+	}
+
+	for _, sem := range sems[:limit] {
+		assert.NoError(t, sem.TryLock(ctx))
+	}
+	assert.Equal(t, distributedlock.ErrLocked, sems[limit].TryLock(ctx))
+
+	// 等待超时后删除排队的键
+	timeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	assert.Error(t, sems[limit].LockContext(timeout))
+	assert.Equal(t, int64(limit), countKeys(t, client, "sem/"))
+
+	assert.NoError(t, sems[0].Unlock())
+	assert.NoError(t, sems[limit].TryLock(ctx))
+	for _, sem := range sems[1:] {
+		assert.NoError(t, sem.Unlock())
+	}
+	assert.Equal(t, int64(0), countKeys(t, client, "sem/"))
+}
+
+func TestSemaphoreConcurrent(t *testing.T) {
+	client := etcdtest.NewClient(t, etcdtest.Run(t))
+	const limit = 2
+	var holders, max int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		sem, err := NewSemaphore("sem", limit, client, WithTTL(5))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			assert.NoError(t, sem.WithLock(ctx, func(ctx context.Context) error {
+				n := atomic.AddInt32(&holders, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(100 * time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(limit), max)
+}
@@ -0,0 +1,53 @@
+package fencing
+
+import (
+	"context"
+	"errors"
+	redisBase "github.com/go-redis/redis/v8"
+	"time"
+)
+
+// Token 防护令牌, 由分布式锁在加锁时生成, 后获得锁的持有者令牌更大
+type Token int64
+
+// ErrStaleToken 令牌小于下游已记录的令牌, 说明锁已被其他持有者获取
+var ErrStaleToken = errors.New("fencing: stale token")
+
+// redisSetScript KEYS[1] 为防护键, KEYS[2] 为数据键; ARGV 为令牌、值、过期毫秒数
+var redisSetScript = redisBase.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) < current then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+if #KEYS > 1 then
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+	else
+		redis.call('SET', KEYS[2], ARGV[2])
+	end
+end
+return 1
+`)
+
+// RedisSet 令牌不小于 fenceKey 中记录的令牌时写入 key 并记录令牌, 否则返回 ErrStaleToken
+// 集群模式下 fenceKey 和 key 需要使用相同的 hash tag, 例如 "{schema}:fence" 和 "{schema}:data"
+func RedisSet(ctx context.Context, client redisBase.UniversalClient, fenceKey, key string, token Token, value interface{}, expiration time.Duration) error {
+	return runRedis(ctx, client, []string{fenceKey, key}, int64(token), value, expiration.Milliseconds())
+}
+
+// RedisCheck 校验并记录令牌, 用于在同一个 redis 中防护其他写入
+func RedisCheck(ctx context.Context, client redisBase.UniversalClient, fenceKey string, token Token) error {
+	return runRedis(ctx, client, []string{fenceKey}, int64(token), "", 0)
+}
+
+func runRedis(ctx context.Context, client redisBase.UniversalClient, keys []string, args ...interface{}) error {
+	ok, err := redisSetScript.Run(ctx, client, keys, args...).Int64()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrStaleToken
+	}
+	return nil
+}
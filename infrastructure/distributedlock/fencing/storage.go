@@ -0,0 +1,73 @@
+package fencing
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/storage"
+	"io"
+	"strconv"
+)
+
+// TokenMetadata 对象元数据中记录令牌的键
+const TokenMetadata = "Fencing-Token"
+
+// Storage 包装 storage.MetadataStorage, 写入前校验对象元数据中的防护令牌, 并把令牌写入对象元数据
+// 对象存储不支持条件写入, 校验和写入之间仍有很小的竞争窗口, 只能缩小而不能消除旧持有者的写入;
+// 令牌随对象一起删除, 对象删除后不再拒绝旧持有者的写入
+type Storage struct {
+	storage.MetadataStorage
+	token Token
+}
+
+func NewStorage(s storage.MetadataStorage, token Token) *Storage {
+	return &Storage{
+		MetadataStorage: s,
+		token:           token,
+	}
+}
+
+func (s *Storage) PutObject(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string) error {
+	return s.PutObjectWithMetadata(ctx, objectName, reader, objectSize, contentType, userTags, nil)
+}
+
+func (s *Storage) PutObjectWithMetadata(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string, metadata map[string]string) error {
+	if err := s.check(ctx, objectName); err != nil {
+		return err
+	}
+	meta := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[TokenMetadata] = strconv.FormatInt(int64(s.token), 10)
+	return s.MetadataStorage.PutObjectWithMetadata(ctx, objectName, reader, objectSize, contentType, userTags, meta)
+}
+
+func (s *Storage) RemoveObject(ctx context.Context, objectName string) error {
+	if err := s.check(ctx, objectName); err != nil {
+		return err
+	}
+	return s.MetadataStorage.RemoveObject(ctx, objectName)
+}
+
+// check 校验对象元数据中的令牌, 对象不存在或没有令牌时通过
+func (s *Storage) check(ctx context.Context, objectName string) error {
+	metadata, err := s.MetadataStorage.GetMetadata(ctx, objectName)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	value, ok := metadata[TokenMetadata]
+	if !ok {
+		return nil
+	}
+	current, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if int64(s.token) < current {
+		return ErrStaleToken
+	}
+	return nil
+}
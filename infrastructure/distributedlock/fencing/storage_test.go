@@ -0,0 +1,64 @@
+package fencing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/storage"
+	"github.com/huskar-t/gopher/infrastructure/storage/fs"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func put(s storage.Storage, objectName, value string) error {
+	return s.PutObject(context.Background(), objectName, bytes.NewReader([]byte(value)), int64(len(value)), "text/plain", nil)
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	base := fs.NewStorage(dir)
+
+	assert.NoError(t, put(NewStorage(base, 2), "schema/device.json", "v2"))
+	metadata, err := base.GetMetadata(ctx, "schema/device.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", metadata[TokenMetadata])
+
+	// 旧持有者的写入和删除被拒绝
+	stale := NewStorage(base, 1)
+	assert.Equal(t, ErrStaleToken, put(stale, "schema/device.json", "v1"))
+	assert.Equal(t, ErrStaleToken, stale.RemoveObject(ctx, "schema/device.json"))
+	data, err := base.GetObject(ctx, "schema/device.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+
+	// 新持有者写入时保留其他元数据
+	assert.NoError(t, NewStorage(base, 3).PutObjectWithMetadata(ctx, "schema/device.json", bytes.NewReader([]byte("v3")), 2, "text/plain", nil, map[string]string{"owner": "edge"}))
+	metadata, err = base.GetMetadata(ctx, "schema/device.json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{TokenMetadata: "3", "Owner": "edge"}, metadata)
+	assert.Equal(t, ErrStaleToken, put(NewStorage(base, 2), "schema/device.json", "v2"))
+
+	// 删除对象时元数据一起删除, 目录中不留下令牌文件
+	assert.NoError(t, NewStorage(base, 3).RemoveObject(ctx, "schema/device.json"))
+	_, err = base.GetMetadata(ctx, "schema/device.json")
+	assert.True(t, errors.Is(err, storage.ErrObjectNotFound))
+	files, err := ioutil.ReadDir(dir + "/schema")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestStorageWithoutToken(t *testing.T) {
+	ctx := context.Background()
+	base := fs.NewStorage(t.TempDir())
+
+	// 没有令牌的对象可以直接写入, 不经过防护的写入会清除令牌
+	assert.NoError(t, put(base, "a", "v0"))
+	assert.NoError(t, put(NewStorage(base, 1), "a", "v1"))
+	assert.NoError(t, put(base, "a", "v2"))
+	metadata, err := base.GetMetadata(ctx, "a")
+	assert.NoError(t, err)
+	assert.Empty(t, metadata)
+	assert.NoError(t, put(NewStorage(base, 1), "a", "v3"))
+}
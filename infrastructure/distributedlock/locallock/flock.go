@@ -0,0 +1,104 @@
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package locallock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// flock 无法被 ctx 打断, 锁被占用时按该间隔轮询
+const retryInterval = 50 * time.Millisecond
+
+// FileMutex 基于 flock 的文件锁, 用于同一主机上的多个进程互斥
+// 锁随文件描述符释放, 进程退出后自动解锁; 锁文件不会被删除
+type FileMutex struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File // 持有时不为 nil
+}
+
+func NewFileMutex(path string) *FileMutex {
+	return &FileMutex{path: path}
+}
+
+func (mutex *FileMutex) Lock() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return mutex.LockContext(ctx)
+}
+
+func (mutex *FileMutex) LockContext(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, true); err != nil {
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *FileMutex) TryLock(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, false); err != nil {
+		if err == distributedlock.ErrLocked {
+			return err
+		}
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *FileMutex) lock(ctx context.Context, wait bool) error {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.f != nil {
+		return errors.New("lock already held")
+	}
+	f, err := os.OpenFile(mutex.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			mutex.f = f
+			return nil
+		}
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			_ = f.Close()
+			return err
+		}
+		if !wait {
+			_ = f.Close()
+			return distributedlock.ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (mutex *FileMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, mutex, fn)
+}
+
+func (mutex *FileMutex) Unlock() error {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.f == nil {
+		return distributedlock.ErrNotHeld
+	}
+	f := mutex.f
+	mutex.f = nil
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,43 @@
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package locallock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"time"
+)
+
+var errUnsupported = errors.New("file lock is not supported on this platform")
+
+// FileMutex 当前平台不支持 flock, 加锁总是返回错误
+type FileMutex struct {
+	path string
+}
+
+func NewFileMutex(path string) *FileMutex {
+	return &FileMutex{path: path}
+}
+
+func (mutex *FileMutex) Lock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return mutex.LockContext(ctx)
+}
+
+func (mutex *FileMutex) LockContext(ctx context.Context) error {
+	return errors.Wrap(errUnsupported, "获取分布式锁失败")
+}
+
+func (mutex *FileMutex) TryLock(ctx context.Context) error {
+	return errors.Wrap(errUnsupported, "获取分布式锁失败")
+}
+
+func (mutex *FileMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, mutex, fn)
+}
+
+func (mutex *FileMutex) Unlock() error {
+	return distributedlock.ErrNotHeld
+}
@@ -0,0 +1,119 @@
+package locallock
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// entry 同一 key 共享的锁, refs 为引用它的加锁请求数, 归零时从注册表删除
+type entry struct {
+	ch   chan struct{}
+	refs int
+}
+
+var registry = struct {
+	sync.Mutex
+	entries map[string]*entry
+}{entries: map[string]*entry{}}
+
+func acquireEntry(key string) *entry {
+	registry.Lock()
+	defer registry.Unlock()
+	e, ok := registry.entries[key]
+	if !ok {
+		e = &entry{ch: make(chan struct{}, 1)}
+		registry.entries[key] = e
+	}
+	e.refs++
+	return e
+}
+
+func releaseEntry(key string, e *entry) {
+	registry.Lock()
+	defer registry.Unlock()
+	e.refs--
+	if e.refs == 0 {
+		delete(registry.entries, key)
+	}
+}
+
+// LocalMutex 进程内按 key 互斥的锁, 用于没有 etcd 的单机部署和单元测试
+// 相同 key 的不同实例互斥, 行为与 etcdlock.EtcdMutex 一致
+type LocalMutex struct {
+	key string
+
+	mu sync.Mutex
+	e  *entry // 持有时不为 nil
+}
+
+func NewMutex(key string) *LocalMutex {
+	return &LocalMutex{key: key}
+}
+
+func (mutex *LocalMutex) Lock() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return mutex.LockContext(ctx)
+}
+
+func (mutex *LocalMutex) LockContext(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, true); err != nil {
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *LocalMutex) TryLock(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, false); err != nil {
+		if err == distributedlock.ErrLocked {
+			return err
+		}
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *LocalMutex) lock(ctx context.Context, wait bool) error {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.e != nil {
+		return errors.New("lock already held")
+	}
+	e := acquireEntry(mutex.key)
+	if wait {
+		select {
+		case e.ch <- struct{}{}:
+		case <-ctx.Done():
+			releaseEntry(mutex.key, e)
+			return ctx.Err()
+		}
+	} else {
+		select {
+		case e.ch <- struct{}{}:
+		default:
+			releaseEntry(mutex.key, e)
+			return distributedlock.ErrLocked
+		}
+	}
+	mutex.e = e
+	return nil
+}
+
+func (mutex *LocalMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, mutex, fn)
+}
+
+func (mutex *LocalMutex) Unlock() error {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.e == nil {
+		return distributedlock.ErrNotHeld
+	}
+	<-mutex.e.ch
+	releaseEntry(mutex.key, mutex.e)
+	mutex.e = nil
+	return nil
+}
@@ -0,0 +1,74 @@
+package locallock
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testLock(t *testing.T, newLock func() distributedlock.DistributedLock) {
+	ctx := context.Background()
+	a, b := newLock(), newLock()
+	assert.NoError(t, a.Lock())
+	assert.Error(t, a.Lock(), "locking twice")
+	assert.Equal(t, distributedlock.ErrLocked, b.TryLock(ctx))
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(b.LockContext(timeout), context.DeadlineExceeded))
+	assert.Equal(t, distributedlock.ErrNotHeld, b.Unlock())
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- b.LockContext(ctx)
+	}()
+	select {
+	case <-acquired:
+		assert.Fail(t, "acquired while locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, a.Unlock())
+	assert.NoError(t, <-acquired)
+	assert.NoError(t, b.Unlock())
+
+	fnErr := errors.New("fn error")
+	assert.Equal(t, fnErr, a.WithLock(ctx, func(ctx context.Context) error { return fnErr }))
+	// WithLock 返回后锁已释放
+	assert.NoError(t, b.TryLock(ctx))
+	assert.NoError(t, b.Unlock())
+}
+
+func TestLocalMutex(t *testing.T) {
+	testLock(t, func() distributedlock.DistributedLock { return NewMutex("test") })
+
+	var wg sync.WaitGroup
+	var counter int
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = NewMutex("counter").WithLock(context.Background(), func(ctx context.Context) error {
+				counter++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, counter)
+	assert.Empty(t, registry.entries)
+}
+
+func TestFileMutex(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "netbsd", "openbsd", "dragonfly":
+	default:
+		t.Skip("file lock is not supported on " + runtime.GOOS)
+	}
+	path := filepath.Join(t.TempDir(), "test.lock")
+	testLock(t, func() distributedlock.DistributedLock { return NewFileMutex(path) })
+}
@@ -0,0 +1,320 @@
+package redislock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockLost 持有期间续期失败, 锁已过期或被其他实例获取
+	ErrLockLost = errors.New("lock lost: lease not extended")
+	// errNotAcquired 本轮未获取到多数节点
+	errNotAcquired = errors.New("lock not acquired")
+	errHeld        = errors.New("lock already held")
+)
+
+// unlockScript 只删除自己持有的锁
+var unlockScript = redisBase.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendScript 只延长自己持有的锁
+var extendScript = redisBase.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+const (
+	defaultExpiration    = 10 * time.Second
+	defaultRetryInterval = 100 * time.Millisecond
+	// redis 过期时间精度为毫秒, 续期间隔为 1/3 租约时间
+	minExpiration = 3 * time.Millisecond
+	// 时钟漂移系数, 参考 Redlock 算法
+	clockDriftFactor = 0.01
+)
+
+type Option func(mutex *RedisMutex)
+
+// WithExpiration 锁的租约时间, 持有期间每 1/3 租约时间自动续期一次, 默认 10s
+// 小于等于 0 时使用默认值, 最小为 3ms
+func WithExpiration(expiration time.Duration) Option {
+	return func(mutex *RedisMutex) {
+		mutex.expiration = expiration
+	}
+}
+
+// WithRetryInterval 锁被占用时的重试间隔, 默认 100ms
+func WithRetryInterval(interval time.Duration) Option {
+	return func(mutex *RedisMutex) {
+		mutex.retryInterval = interval
+	}
+}
+
+type RedisMutex struct {
+	key           string
+	clients       []redisBase.UniversalClient
+	expiration    time.Duration
+	retryInterval time.Duration
+
+	mu     sync.Mutex
+	token  string
+	cancel context.CancelFunc
+	lost   chan struct{}
+	done   chan struct{}
+}
+
+// NewMutex 基于单个 redis(或集群) 的分布式锁
+func NewMutex(key string, client redisBase.UniversalClient, opts ...Option) *RedisMutex {
+	return newMutex(key, []redisBase.UniversalClient{client}, opts)
+}
+
+// NewRedlock 基于多个相互独立 redis 节点的 Redlock 分布式锁, 需要在多数节点上加锁成功
+func NewRedlock(key string, clients []redisBase.UniversalClient, opts ...Option) (*RedisMutex, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("redlock requires at least one client")
+	}
+	return newMutex(key, clients, opts), nil
+}
+
+func newMutex(key string, clients []redisBase.UniversalClient, opts []Option) *RedisMutex {
+	mutex := &RedisMutex{
+		key:           key,
+		clients:       clients,
+		expiration:    defaultExpiration,
+		retryInterval: defaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(mutex)
+	}
+	if mutex.expiration <= 0 {
+		mutex.expiration = defaultExpiration
+	} else if mutex.expiration < minExpiration {
+		mutex.expiration = minExpiration
+	}
+	if mutex.retryInterval <= 0 {
+		mutex.retryInterval = defaultRetryInterval
+	}
+	return mutex
+}
+
+func (mutex *RedisMutex) Lock() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //设置5s超时
+	defer cancel()
+	return mutex.LockContext(ctx)
+}
+
+func (mutex *RedisMutex) LockContext(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, true); err != nil {
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+func (mutex *RedisMutex) TryLock(ctx context.Context) (err error) {
+	if err = mutex.lock(ctx, false); err != nil {
+		if err == errNotAcquired {
+			return distributedlock.ErrLocked
+		}
+		err = errors.Wrap(err, "获取分布式锁失败")
+	}
+	return
+}
+
+// WithLock 执行 fn 时传入的 ctx 会在锁丢失时取消
+func (mutex *RedisMutex) WithLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return distributedlock.WithLock(ctx, mutex, func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		lost := mutex.Lost()
+		go func() {
+			select {
+			case <-lost:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		return fn(ctx)
+	})
+}
+
+// Lost 返回当前持有的锁续期失败时关闭的通道, 未持有锁时返回 nil
+func (mutex *RedisMutex) Lost() <-chan struct{} {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.token == "" {
+		return nil
+	}
+	return mutex.lost
+}
+
+// Unlock 释放锁, 锁已丢失时返回 ErrLockLost
+func (mutex *RedisMutex) Unlock() (err error) {
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	if mutex.token == "" {
+		return distributedlock.ErrNotHeld
+	}
+	mutex.cancel()
+	<-mutex.done
+	n := mutex.release(context.Background(), mutex.token)
+	mutex.token = ""
+	select {
+	case <-mutex.lost:
+		return ErrLockLost
+	default:
+	}
+	if n < mutex.quorum() {
+		return distributedlock.ErrNotHeld
+	}
+	return nil
+}
+
+// lock 获取锁, wait 为 false 时只尝试一次
+// 等待期间不持有 mu, 只在检查和设置持有状态时加锁
+func (mutex *RedisMutex) lock(ctx context.Context, wait bool) error {
+	mutex.mu.Lock()
+	held := mutex.token != ""
+	mutex.mu.Unlock()
+	if held {
+		return errHeld
+	}
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	var validUntil time.Time
+	for {
+		validUntil, err = mutex.acquire(ctx, token)
+		if err == nil {
+			break
+		}
+		if err != errNotAcquired || !wait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mutex.retryInterval):
+		}
+	}
+	mutex.mu.Lock()
+	defer mutex.mu.Unlock()
+	// 同一实例上并发的加锁已先成功
+	if mutex.token != "" {
+		mutex.release(context.Background(), token)
+		return errHeld
+	}
+	mutex.token = token
+	refreshCtx, cancel := context.WithCancel(context.Background())
+	mutex.cancel = cancel
+	mutex.lost = make(chan struct{})
+	mutex.done = make(chan struct{})
+	go mutex.refresh(refreshCtx, token, validUntil, mutex.lost, mutex.done)
+	return nil
+}
+
+// acquire 在所有节点上尝试加锁, 多数节点成功且剩余有效时间为正时成功, 返回锁的有效期
+func (mutex *RedisMutex) acquire(ctx context.Context, token string) (time.Time, error) {
+	start := time.Now()
+	var n, failed int
+	var lastErr error
+	for _, client := range mutex.clients {
+		ok, err := client.SetNX(ctx, mutex.key, token, mutex.expiration).Result()
+		if err != nil {
+			lastErr = err
+			failed++
+			continue
+		}
+		if ok {
+			n++
+		}
+	}
+	validUntil := start.Add(mutex.expiration - mutex.drift())
+	if n >= mutex.quorum() && time.Now().Before(validUntil) {
+		return validUntil, nil
+	}
+	mutex.release(context.Background(), token)
+	if failed == len(mutex.clients) {
+		return time.Time{}, lastErr
+	}
+	return time.Time{}, errNotAcquired
+}
+
+// release 删除所有节点上自己持有的锁, 返回删除成功的节点数
+func (mutex *RedisMutex) release(ctx context.Context, token string) int {
+	var n int
+	for _, client := range mutex.clients {
+		deleted, err := unlockScript.Run(ctx, client, []string{mutex.key}, token).Int64()
+		if err == nil && deleted == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// refresh 持有期间定期续期, 多数节点上的锁已不属于自己或有效期内未能续期时关闭 lost 并停止续期
+func (mutex *RedisMutex) refresh(ctx context.Context, token string, validUntil time.Time, lost, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(mutex.expiration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			var extended, rejected int
+			for _, client := range mutex.clients {
+				n, err := extendScript.Run(ctx, client, []string{mutex.key}, token, mutex.expiration.Milliseconds()).Int64()
+				if err != nil {
+					continue
+				}
+				if n == 1 {
+					extended++
+				} else {
+					rejected++
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if extended >= mutex.quorum() {
+				validUntil = start.Add(mutex.expiration - mutex.drift())
+				continue
+			}
+			// 续期出错时在有效期内继续重试
+			if rejected > len(mutex.clients)-mutex.quorum() || !time.Now().Before(validUntil) {
+				close(lost)
+				return
+			}
+		}
+	}
+}
+
+func (mutex *RedisMutex) quorum() int {
+	return len(mutex.clients)/2 + 1
+}
+
+// drift 时钟漂移和网络延迟的容差
+func (mutex *RedisMutex) drift() time.Duration {
+	return time.Duration(float64(mutex.expiration)*clockDriftFactor) + 2*time.Millisecond
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
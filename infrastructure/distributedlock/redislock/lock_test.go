@@ -0,0 +1,133 @@
+package redislock
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/common/define/distributedlock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, redisBase.UniversalClient) {
+	s, err := miniredis.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(s.Close)
+	client := redisBase.NewClient(&redisBase.Options{Addr: s.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return s, client
+}
+
+func TestMutex(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	a := NewMutex("lock", client, WithExpiration(time.Second))
+	b := NewMutex("lock", client, WithExpiration(time.Second))
+
+	assert.NoError(t, a.Lock())
+	assert.NotNil(t, a.Lost())
+	assert.Equal(t, distributedlock.ErrLocked, b.TryLock(ctx))
+	assert.Equal(t, distributedlock.ErrNotHeld, b.Unlock())
+	assert.NoError(t, a.Unlock())
+	assert.Nil(t, a.Lost())
+	assert.NoError(t, b.TryLock(ctx))
+	assert.NoError(t, b.Unlock())
+}
+
+func TestLockWaiting(t *testing.T) {
+	_, client := newClient(t)
+	a := NewMutex("lock", client, WithExpiration(time.Second), WithRetryInterval(10*time.Millisecond))
+	b := NewMutex("lock", client, WithExpiration(time.Second))
+	assert.NoError(t, b.Lock())
+
+	locked := make(chan error, 1)
+	go func() { locked <- a.Lock() }()
+	time.Sleep(50 * time.Millisecond)
+
+	// 等待加锁期间其他方法不被阻塞
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		assert.Nil(t, a.Lost())
+		assert.Equal(t, distributedlock.ErrNotHeld, a.Unlock())
+	}()
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		t.Fatal("blocked by waiting lock")
+	}
+
+	assert.NoError(t, b.Unlock())
+	assert.NoError(t, <-locked)
+	assert.NotNil(t, a.Lost())
+	assert.Error(t, a.TryLock(context.Background()))
+	assert.NoError(t, a.Unlock())
+}
+
+func TestExpiration(t *testing.T) {
+	_, client := newClient(t)
+	for _, c := range []struct {
+		expiration time.Duration
+		expect     time.Duration
+	}{
+		{0, defaultExpiration},
+		{-time.Second, defaultExpiration},
+		{time.Nanosecond, minExpiration},
+		{time.Second, time.Second},
+	} {
+		mutex := NewMutex("lock", client, WithExpiration(c.expiration))
+		assert.Equal(t, c.expect, mutex.expiration)
+		assert.NoError(t, mutex.Lock())
+		assert.NoError(t, mutex.Unlock())
+	}
+}
+
+func TestLost(t *testing.T) {
+	s, client := newClient(t)
+	mutex := NewMutex("lock", client, WithExpiration(30*time.Millisecond))
+	var lost bool
+	err := mutex.WithLock(context.Background(), func(ctx context.Context) error {
+		// 锁被其他实例获取后续期失败
+		assert.NoError(t, s.Set("lock", "other"))
+		select {
+		case <-ctx.Done():
+			lost = true
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+	assert.True(t, lost)
+	assert.Equal(t, ErrLockLost, err)
+	value, err := s.Get("lock")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestRedlock(t *testing.T) {
+	ctx := context.Background()
+	var clients []redisBase.UniversalClient
+	var servers []*miniredis.Miniredis
+	for i := 0; i < 3; i++ {
+		s, client := newClient(t)
+		servers = append(servers, s)
+		clients = append(clients, client)
+	}
+	_, err := NewRedlock("lock", nil)
+	assert.Error(t, err)
+
+	a, err := NewRedlock("lock", clients, WithExpiration(time.Second))
+	assert.NoError(t, err)
+	b, err := NewRedlock("lock", clients, WithExpiration(time.Second))
+	assert.NoError(t, err)
+
+	// 少数节点不可用时仍然可以加锁
+	servers[0].Close()
+	assert.NoError(t, a.TryLock(ctx))
+	assert.Equal(t, distributedlock.ErrLocked, b.TryLock(ctx))
+	assert.NoError(t, a.Unlock())
+	assert.NoError(t, b.TryLock(ctx))
+	assert.NoError(t, b.Unlock())
+}
@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	natsmq "github.com/huskar-t/gopher/infrastructure/mq/nats"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStopped         = errors.New("mq: broker stopped")
+	ErrInvalidTopic    = errors.New("mq: invalid topic")
+	ErrBadSubscription = errors.New("mq: invalid subscription")
+)
+
+// Memory 进程内的 mq.MQ 实现, 通配符与队列组语义与 nats 实现一致
+// 消息在 Publish 中同步投递, Publish 返回时所有回调已执行完毕, 便于编写确定性的测试
+type Memory struct {
+	encoder *natsmq.JsonEncoder
+
+	mu      sync.Mutex
+	stopped bool
+	subs    []*subscription // 按订阅顺序投递
+	groups  []*queueGroup
+}
+
+// queueGroup 同一主题和组名的订阅, 每条消息轮询投递给其中一个
+type queueGroup struct {
+	topic   string
+	group   string
+	members []*subscription
+	next    int
+}
+
+type subscription struct {
+	m       *Memory
+	topic   string
+	group   string
+	cb      mq.CallBack
+	handler mq.MsgHandler
+	raw     bool // handler 接收未解码的 []byte
+	respond mq.Responder
+}
+
+func NewMemoryMQ() mq.MQ {
+	return &Memory{
+		encoder: &natsmq.JsonEncoder{},
+	}
+}
+
+func (m *Memory) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+	m.subs = nil
+	m.groups = nil
+}
+
+func (m *Memory) Publish(topic string, data interface{}) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	return m.publish(&mq.Message{Topic: topic, Data: data})
+}
+
+// PublishMsg 与 nats 实现一样复制 msg 并补全 ID、时间戳和 ctx 中的头部
+func (m *Memory) PublishMsg(ctx context.Context, topic string, msg *mq.Message) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	msg = msg.Clone()
+	if msg.ID == "" {
+		msg.ID = mq.NewMessageID()
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg.Topic = topic
+	msg.Header = mq.InjectHeader(ctx, msg.Header)
+	return m.publish(msg)
+}
+
+func (m *Memory) publish(msg *mq.Message) error {
+	payload, err := m.encoder.Encode(msg.Topic, msg.Data)
+	if err != nil {
+		return err
+	}
+	targets, err := m.targets(msg.Topic)
+	if err != nil {
+		return err
+	}
+	for _, sub := range targets {
+		m.deliver(sub, msg, payload)
+	}
+	return nil
+}
+
+// Request 同步投递请求, 使用第一个响应方的结果, 其他订阅者与 Publish 一样收到请求
+func (m *Memory) Request(ctx context.Context, topic string, data interface{}, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	msg := &mq.Message{Topic: topic, Header: mq.InjectHeader(ctx, nil)}
+	payload, err := m.encoder.Encode(topic, data)
+	if err != nil {
+		return err
+	}
+	targets, err := m.targets(topic)
+	if err != nil {
+		return err
+	}
+	var resp []byte
+	var respErr error
+	responded := false
+	for _, sub := range targets {
+		data, ok, err := m.deliver(sub, msg, payload)
+		if ok && !responded {
+			resp, respErr, responded = data, err, true
+		}
+	}
+	if !responded {
+		return mq.ErrNoResponders
+	}
+	if respErr != nil {
+		return &mq.ResponseError{Message: respErr.Error()}
+	}
+	if reply == nil {
+		return nil
+	}
+	return m.encoder.Decode(topic, resp, reply)
+}
+
+// targets 返回消息的接收者, 每个匹配的队列组只选出一个成员
+func (m *Memory) targets(topic string) ([]*subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, ErrStopped
+	}
+	var targets []*subscription
+	for _, sub := range m.subs {
+		if match(sub.topic, topic) {
+			targets = append(targets, sub)
+		}
+	}
+	for _, group := range m.groups {
+		if match(group.topic, topic) {
+			targets = append(targets, group.members[group.next%len(group.members)])
+			group.next++
+		}
+	}
+	return targets, nil
+}
+
+// deliver 与 nats EncodedConn 一样为每个订阅单独解码, 普通订阅解码失败时丢弃
+// 响应方订阅返回编码后的响应, ok 表示订阅是响应方
+func (m *Memory) deliver(sub *subscription, msg *mq.Message, payload []byte) (resp []byte, ok bool, err error) {
+	topic := msg.Topic
+	if sub.raw {
+		sub.handler(received(msg, append([]byte(nil), payload...)))
+		return nil, false, nil
+	}
+	var message interface{}
+	err = m.encoder.Decode(topic, payload, &message)
+	if sub.respond == nil {
+		if err != nil {
+			return nil, false, nil
+		}
+		if sub.handler == nil {
+			sub.cb(topic, message)
+			return nil, false, nil
+		}
+		sub.handler(received(msg, message))
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	result, err := sub.respond(topic, message)
+	if err != nil {
+		return nil, true, err
+	}
+	resp, err = m.encoder.Encode(topic, result)
+	return resp, true, err
+}
+
+// received 复制消息信封, 每个订阅者收到独立的头部
+func received(msg *mq.Message, data interface{}) (context.Context, *mq.Message) {
+	r := msg.Clone()
+	r.Data = data
+	if r.Header == nil {
+		r.Header = map[string]string{}
+	}
+	return mq.ExtractHeader(context.Background(), r.Header), r
+}
+
+func (m *Memory) Subscribe(topic string, cb mq.CallBack) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, cb: cb})
+}
+
+func (m *Memory) GroupSubscribe(topic, group string, cb mq.CallBack) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, group: group, cb: cb})
+}
+
+func (m *Memory) SubscribeMsg(topic string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, handler: handler})
+}
+
+func (m *Memory) GroupSubscribeMsg(topic, group string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, group: group, handler: handler})
+}
+
+func (m *Memory) SubscribeRaw(topic string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, handler: handler, raw: true})
+}
+
+func (m *Memory) GroupSubscribeRaw(topic, group string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, group: group, handler: handler, raw: true})
+}
+
+func (m *Memory) Respond(topic, group string, handler mq.Responder) (mq.Subscriber, error) {
+	return m.subscribe(&subscription{m: m, topic: topic, group: group, respond: handler})
+}
+
+func (m *Memory) subscribe(sub *subscription) (mq.Subscriber, error) {
+	if sub.topic == "" {
+		return nil, ErrInvalidTopic
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, ErrStopped
+	}
+	sub.topic = natsmq.ChangeTopic(sub.topic)
+	if sub.group == "" {
+		m.subs = append(m.subs, sub)
+		return sub, nil
+	}
+	for _, g := range m.groups {
+		if g.topic == sub.topic && g.group == sub.group {
+			g.members = append(g.members, sub)
+			return sub, nil
+		}
+	}
+	m.groups = append(m.groups, &queueGroup{topic: sub.topic, group: sub.group, members: []*subscription{sub}})
+	return sub, nil
+}
+
+func (sub *subscription) Unsubscribe() error {
+	m := sub.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sub.group == "" {
+		for i, s := range m.subs {
+			if s == sub {
+				m.subs = append(m.subs[:i], m.subs[i+1:]...)
+				return nil
+			}
+		}
+		return ErrBadSubscription
+	}
+	for i, g := range m.groups {
+		if g.topic != sub.topic || g.group != sub.group {
+			continue
+		}
+		for j, member := range g.members {
+			if member == sub {
+				g.members = append(g.members[:j], g.members[j+1:]...)
+				if len(g.members) == 0 {
+					m.groups = append(m.groups[:i], m.groups[i+1:]...)
+				}
+				return nil
+			}
+		}
+	}
+	return ErrBadSubscription
+}
+
+// match 按 nats 规则匹配主题, "*" 匹配一级, ">" 匹配剩余的一级或多级
+func match(pattern, topic string) bool {
+	pt := strings.Split(pattern, ".")
+	tt := strings.Split(topic, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return len(tt) > i
+		}
+		if i >= len(tt) {
+			return false
+		}
+		if p != "*" && p != tt[i] {
+			return false
+		}
+	}
+	return len(pt) == len(tt)
+}
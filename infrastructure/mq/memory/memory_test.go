@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWildcard(t *testing.T) {
+	m := NewMemoryMQ()
+	defer m.Stop()
+	var got []string
+	for _, topic := range []string{"foo.*", "foo*", "foo.bar", "foo.*.baz"} {
+		topic := topic
+		_, err := m.Subscribe(topic, func(subject string, message interface{}) {
+			got = append(got, topic+"<-"+subject)
+		})
+		assert.NoError(t, err)
+	}
+	for _, subject := range []string{"foo", "foo.bar", "foo.bar.baz", "foobar"} {
+		assert.NoError(t, m.Publish(subject, 1))
+	}
+	assert.Equal(t, []string{
+		"foo.*<-foo.bar", "foo*<-foo.bar", "foo.bar<-foo.bar",
+		"foo.*<-foo.bar.baz", "foo*<-foo.bar.baz", "foo.*.baz<-foo.bar.baz",
+	}, got)
+}
+
+func TestDecode(t *testing.T) {
+	m := NewMemoryMQ()
+	defer m.Stop()
+	var got interface{}
+	_, err := m.Subscribe("data", func(topic string, message interface{}) {
+		got = message
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Publish("data", map[string]interface{}{"value": 1.5, "name": "a"}))
+	assert.Equal(t, map[string]interface{}{"value": json.Number("1.5"), "name": "a"}, got)
+	assert.NoError(t, m.Publish("data", "text"))
+	assert.Equal(t, "text", got)
+}
+
+func TestGroupSubscribe(t *testing.T) {
+	m := NewMemoryMQ()
+	counts := map[string]int{}
+	subscribe := func(name, group string) {
+		_, err := m.GroupSubscribe("job.*", group, func(topic string, message interface{}) {
+			counts[name]++
+		})
+		assert.NoError(t, err)
+	}
+	subscribe("a1", "a")
+	subscribe("a2", "a")
+	subscribe("b1", "b")
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, m.Publish("job.run", i))
+	}
+	assert.Equal(t, map[string]int{"a1": 2, "a2": 2, "b1": 4}, counts)
+
+	sub, err := m.GroupSubscribe("job.*", "b", func(topic string, message interface{}) {})
+	assert.NoError(t, err)
+	assert.NoError(t, sub.Unsubscribe())
+	assert.Equal(t, ErrBadSubscription, sub.Unsubscribe())
+	m.Stop()
+	assert.Equal(t, ErrStopped, m.Publish("job.run", 1))
+}
+
+func TestRequest(t *testing.T) {
+	m := NewMemoryMQ()
+	defer m.Stop()
+	ctx := context.Background()
+	assert.Equal(t, mq.ErrNoResponders, m.Request(ctx, "device.config", nil, nil))
+	_, err := m.Respond("device.*", "edge", func(topic string, request interface{}) (interface{}, error) {
+		if topic == "device.restart" {
+			return nil, errors.New("device busy")
+		}
+		return map[string]interface{}{"topic": topic, "request": request}, nil
+	})
+	assert.NoError(t, err)
+	var reply map[string]interface{}
+	assert.NoError(t, m.Request(ctx, "device.config", "read", &reply))
+	assert.Equal(t, map[string]interface{}{"topic": "device.config", "request": "read"}, reply)
+	err = m.Request(ctx, "device.restart", nil, nil)
+	var respErr *mq.ResponseError
+	if assert.True(t, errors.As(err, &respErr), "got %v", err) {
+		assert.Equal(t, "device busy", respErr.Message)
+	}
+}
+
+func TestPublishMsg(t *testing.T) {
+	m := NewMemoryMQ()
+	defer m.Stop()
+	received := make(chan *mq.Message, 1)
+	var traceID string
+	_, err := m.SubscribeMsg("event.*", func(ctx context.Context, msg *mq.Message) {
+		traceID = mq.TraceID(ctx)
+		received <- msg
+	})
+	assert.NoError(t, err)
+	ctx := mq.WithEdgeID(mq.WithTraceID(context.Background(), "trace-1"), "edge-1")
+	msg := &mq.Message{Header: map[string]string{"Kind": "alarm"}, Data: 1}
+	assert.NoError(t, m.PublishMsg(ctx, "event.d1", msg))
+	got := <-received
+	assert.NotEmpty(t, got.ID)
+	// 不修改调用方的 msg
+	assert.Equal(t, &mq.Message{Header: map[string]string{"Kind": "alarm"}, Data: 1}, msg)
+	assert.False(t, got.Timestamp.IsZero())
+	assert.Equal(t, "event.d1", got.Topic)
+	assert.Equal(t, map[string]string{"Kind": "alarm", mq.HeaderTraceID: "trace-1", mq.HeaderEdgeID: "edge-1"}, got.Header)
+	assert.Equal(t, json.Number("1"), got.Data)
+	assert.Equal(t, "trace-1", traceID)
+}
+
+func TestSubscribeRaw(t *testing.T) {
+	m := NewMemoryMQ()
+	defer m.Stop()
+	var raw []interface{}
+	for i := 0; i < 2; i++ {
+		_, err := m.SubscribeRaw("event.*", func(ctx context.Context, msg *mq.Message) {
+			raw = append(raw, msg.Data)
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, m.Publish("event.d1", map[string]interface{}{"value": 1}))
+	assert.Equal(t, []interface{}{[]byte(`{"value":1}`), []byte(`{"value":1}`)}, raw)
+	// 每个订阅者收到独立的副本
+	raw[0].([]byte)[0] = '['
+	assert.Equal(t, []byte(`{"value":1}`), raw[1])
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"sync/atomic"
+)
+
+type deliveryKey struct{}
+
+// WithDelivery 返回带有持久化消息确认操作的 ctx
+func WithDelivery(ctx context.Context, delivery mq.Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+// DeliveryFromContext 读取 ChainDurable 写入的确认操作, 不是持久化消息时返回 nil
+func DeliveryFromContext(ctx context.Context) mq.Delivery {
+	delivery, _ := ctx.Value(deliveryKey{}).(mq.Delivery)
+	return delivery
+}
+
+// settledDelivery 记录消息是否已被确认或拒绝, 避免重复确认
+type settledDelivery struct {
+	mq.Delivery
+	settled int32
+}
+
+func (d *settledDelivery) Ack() error {
+	atomic.StoreInt32(&d.settled, 1)
+	return d.Delivery.Ack()
+}
+
+func (d *settledDelivery) Nak() error {
+	atomic.StoreInt32(&d.settled, 1)
+	return d.Delivery.Nak()
+}
+
+func (d *settledDelivery) Term() error {
+	atomic.StoreInt32(&d.settled, 1)
+	return d.Delivery.Term()
+}
+
+// ChainDurable 与 Chain 相同, 用于 mq.DurableCallBack 订阅, 确认操作通过 DeliveryFromContext 获取
+// handler 和中间件都没有确认时, 处理成功 Ack, 返回错误 Nak
+func ChainDurable(handler Handler, middlewares ...Middleware) mq.DurableCallBack {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return func(topic string, message interface{}, delivery mq.Delivery) {
+		d := &settledDelivery{Delivery: delivery}
+		err := handler(WithDelivery(context.Background(), d), &mq.Message{Topic: topic, Data: message})
+		if atomic.LoadInt32(&d.settled) == 1 {
+			return
+		}
+		if err != nil {
+			_ = d.Nak()
+			return
+		}
+		_ = d.Ack()
+	}
+}
+
+func DurableSubscribe(consumer mq.DurableConsumer, topic string, handler Handler, middlewares ...Middleware) (mq.Subscriber, error) {
+	return consumer.DurableSubscribe(topic, ChainDurable(handler, middlewares...))
+}
+
+func DurableGroupSubscribe(consumer mq.DurableConsumer, topic, group string, handler Handler, middlewares ...Middleware) (mq.Subscriber, error) {
+	return consumer.DurableGroupSubscribe(topic, group, ChainDurable(handler, middlewares...))
+}
+
+// retryDelivery 持久化消息的重试交给服务端重新投递
+func retryDelivery(ctx context.Context, msg *mq.Message, next Handler, delivery mq.Delivery, attempts int) error {
+	err := next(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	if delivery.Attempt() < attempts {
+		if e := delivery.Nak(); e != nil {
+			return err
+		}
+		return nil
+	}
+	_ = delivery.Term()
+	return err
+}
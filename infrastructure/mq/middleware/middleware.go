@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/huskar-t/gopher/infrastructure/log"
+	"github.com/sirupsen/logrus"
+	"runtime/debug"
+	"time"
+)
+
+// 死信消息的头部
+const (
+	HeaderDeadReason = "X-Dead-Reason" // 失败原因
+	HeaderDeadTopic  = "X-Dead-Topic"  // 原始主题
+	HeaderDeadAt     = "X-Dead-At"     // 进入死信的时间, RFC3339
+)
+
+// Handler 处理消息, 返回的错误交给外层中间件处理
+type Handler func(ctx context.Context, msg *mq.Message) error
+
+// Middleware 包装 Handler
+type Middleware func(next Handler) Handler
+
+// PanicError handler panic 时 Recover 返回的错误
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("mq: handler panic: %v", e.Value)
+}
+
+// Chain 组合中间件, 第一个中间件在最外层, 如
+// Chain(h, Logging(nil), DeadLetter(p, "dead"), Retry(3, time.Second), Recover())
+// 最终仍未处理的错误被丢弃
+func Chain(handler Handler, middlewares ...Middleware) mq.MsgHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return func(ctx context.Context, msg *mq.Message) {
+		_ = handler(ctx, msg)
+	}
+}
+
+// ChainCallBack 与 Chain 相同, 用于只接收主题和数据的 mq.CallBack 订阅, msg 中只有 Topic 和 Data
+func ChainCallBack(handler Handler, middlewares ...Middleware) mq.CallBack {
+	h := Chain(handler, middlewares...)
+	return func(topic string, message interface{}) {
+		h(context.Background(), &mq.Message{Topic: topic, Data: message})
+	}
+}
+
+func Subscribe(consumer mq.Consumer, topic string, handler Handler, middlewares ...Middleware) (mq.Subscriber, error) {
+	return consumer.SubscribeMsg(topic, Chain(handler, middlewares...))
+}
+
+func GroupSubscribe(consumer mq.Consumer, topic, group string, handler Handler, middlewares ...Middleware) (mq.Subscriber, error) {
+	return consumer.GroupSubscribeMsg(topic, group, Chain(handler, middlewares...))
+}
+
+// Recover 把 handler 的 panic 转换为 *PanicError, 放在 Retry 内层时 panic 也会重试
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *mq.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Retry 失败后重试, attempts 为最多执行次数
+// 第 n 次重试前等待 backoff 中第 n 个延迟, 超出时使用最后一个, 未设置时立即重试
+// 持久化消息(ChainDurable)不在进程内重试: 投递次数未达到 attempts 时 Nak 交给服务端重新投递并视为已处理,
+// 达到后 Term 不再投递并返回错误, 此时 backoff 不生效, 重新投递的延迟由订阅配置决定
+func Retry(attempts int, backoff ...time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *mq.Message) error {
+			if delivery := DeliveryFromContext(ctx); delivery != nil {
+				return retryDelivery(ctx, msg, next, delivery, attempts)
+			}
+			var err error
+			for i := 0; i < attempts || i == 0; i++ {
+				if i > 0 && len(backoff) > 0 {
+					delay := backoff[len(backoff)-1]
+					if i <= len(backoff) {
+						delay = backoff[i-1]
+					}
+					timer := time.NewTimer(delay)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return err
+					case <-timer.C:
+					}
+				}
+				if err = next(ctx, msg); err == nil {
+					return nil
+				}
+			}
+			return err
+		}
+	}
+}
+
+// DeadLetter 处理失败的消息发布到 topic, 失败原因和原始主题写入消息头
+// 发布成功时视为已处理, 发布失败时返回原错误
+func DeadLetter(producer mq.Producer, topic string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *mq.Message) error {
+			err := next(ctx, msg)
+			if err == nil {
+				return nil
+			}
+			header := make(map[string]string, len(msg.Header)+3)
+			for key, value := range msg.Header {
+				header[key] = value
+			}
+			header[HeaderDeadReason] = err.Error()
+			header[HeaderDeadTopic] = msg.Topic
+			header[HeaderDeadAt] = time.Now().Format(time.RFC3339)
+			dead := &mq.Message{
+				ID:        msg.ID,
+				Header:    header,
+				Data:      msg.Data,
+				Timestamp: msg.Timestamp,
+			}
+			if e := producer.PublishMsg(ctx, topic, dead); e != nil {
+				log.GetLogger("mq").WithError(e).WithField("topic", msg.Topic).Error("publish dead letter error")
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// Logging 记录处理结果和耗时, logger 为空时使用 log.GetLogger("mq")
+func Logging(logger logrus.FieldLogger) Middleware {
+	if logger == nil {
+		logger = log.GetLogger("mq")
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *mq.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			entry := logger.WithFields(logrus.Fields{
+				"topic":   msg.Topic,
+				"msgID":   msg.ID,
+				"traceID": mq.TraceID(ctx),
+				"cost":    time.Since(start).String(),
+			})
+			if err != nil {
+				if p, ok := err.(*PanicError); ok {
+					entry = entry.WithField("stack", string(p.Stack))
+				}
+				entry.WithError(err).Error("handle message error")
+			} else {
+				entry.Debug("handle message")
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/huskar-t/gopher/infrastructure/mq/memory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	m := memory.NewMemoryMQ()
+	defer m.Stop()
+	var dead []*mq.Message
+	_, err := m.SubscribeMsg("dead", func(ctx context.Context, msg *mq.Message) {
+		dead = append(dead, msg)
+	})
+	assert.NoError(t, err)
+	attempts := map[interface{}]int{}
+	_, err = Subscribe(m, "order.*", func(ctx context.Context, msg *mq.Message) error {
+		attempts[msg.Data]++
+		switch msg.Data {
+		case "panic":
+			panic("boom")
+		case "flaky":
+			if attempts[msg.Data] < 2 {
+				return errors.New("temporary")
+			}
+		}
+		return nil
+	}, Logging(nil), DeadLetter(m, "dead"), Retry(3, time.Millisecond), Recover())
+	assert.NoError(t, err)
+	for _, data := range []string{"ok", "flaky", "panic"} {
+		assert.NoError(t, m.PublishMsg(mq.WithTraceID(context.Background(), "t1"), "order.created", &mq.Message{Data: data}))
+	}
+	assert.Equal(t, map[interface{}]int{"ok": 1, "flaky": 2, "panic": 3}, attempts)
+	if !assert.Len(t, dead, 1) {
+		return
+	}
+	assert.Equal(t, "panic", dead[0].Data)
+	assert.Equal(t, "order.created", dead[0].Header[HeaderDeadTopic])
+	assert.Equal(t, "mq: handler panic: boom", dead[0].Header[HeaderDeadReason])
+	assert.Equal(t, "t1", dead[0].Header[mq.HeaderTraceID])
+}
+
+func TestChainCallBack(t *testing.T) {
+	m := memory.NewMemoryMQ()
+	defer m.Stop()
+	var calls int
+	var got *mq.Message
+	_, err := m.Subscribe("order.*", ChainCallBack(func(ctx context.Context, msg *mq.Message) error {
+		calls++
+		got = msg
+		if calls < 2 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, Retry(3), Recover()))
+	assert.NoError(t, err)
+	assert.NoError(t, m.Publish("order.created", "o1"))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, &mq.Message{Topic: "order.created", Data: "o1"}, got)
+}
+
+type fakeDelivery struct {
+	attempt int
+	acks    []string
+}
+
+func (d *fakeDelivery) Ack() error {
+	d.acks = append(d.acks, "ack")
+	return nil
+}
+
+func (d *fakeDelivery) Nak() error {
+	d.acks = append(d.acks, "nak")
+	return nil
+}
+
+func (d *fakeDelivery) Term() error {
+	d.acks = append(d.acks, "term")
+	return nil
+}
+
+func (d *fakeDelivery) Attempt() int {
+	return d.attempt
+}
+
+func TestChainDurable(t *testing.T) {
+	m := memory.NewMemoryMQ()
+	defer m.Stop()
+	var dead []*mq.Message
+	_, err := m.SubscribeMsg("dead", func(ctx context.Context, msg *mq.Message) {
+		dead = append(dead, msg)
+	})
+	assert.NoError(t, err)
+	var calls int
+	cb := ChainDurable(func(ctx context.Context, msg *mq.Message) error {
+		calls++
+		switch msg.Data {
+		case "fail":
+			return errors.New("failed")
+		case "manual":
+			return DeliveryFromContext(ctx).Term()
+		}
+		return nil
+	}, DeadLetter(m, "dead"), Retry(3, time.Hour))
+
+	for _, c := range []struct {
+		data    string
+		attempt int
+		acks    []string
+	}{
+		{"ok", 1, []string{"ack"}},
+		// 未达到次数时交给服务端重新投递, 不在进程内等待
+		{"fail", 1, []string{"nak"}},
+		{"fail", 2, []string{"nak"}},
+		// 达到次数后不再投递, 进入死信
+		{"fail", 3, []string{"term"}},
+		// handler 自行确认后不再确认
+		{"manual", 1, []string{"term"}},
+	} {
+		calls = 0
+		d := &fakeDelivery{attempt: c.attempt}
+		cb("order.created", c.data, d)
+		assert.Equal(t, 1, calls, c.data)
+		assert.Equal(t, c.acks, d.acks, c.data)
+	}
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, "fail", dead[0].Data)
+		assert.Equal(t, "failed", dead[0].Header[HeaderDeadReason])
+	}
+
+	// 没有 Retry 时失败的消息 Nak
+	d := &fakeDelivery{attempt: 1}
+	ChainDurable(func(ctx context.Context, msg *mq.Message) error {
+		return errors.New("failed")
+	})("order.created", "fail", d)
+	assert.Equal(t, []string{"nak"}, d.acks)
+}
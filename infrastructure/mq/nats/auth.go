@@ -0,0 +1,189 @@
+package nats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ConfigError 配置错误, Field 为配置项名称, 如 TLS.CertFile
+type ConfigError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("nats config %s: %s", e.Field, e.Reason)
+}
+
+// Validate 检查地址格式、认证方式和证书文件
+func (conf *Config) Validate() error {
+	for i, server := range conf.Servers {
+		if err := validateURL(server); err != nil {
+			return &ConfigError{Field: fmt.Sprintf("Servers[%d]", i), Reason: err.Error()}
+		}
+	}
+	if len(conf.Servers) == 0 && conf.Addr != "" {
+		if err := validateURL(conf.Addr); err != nil {
+			return &ConfigError{Field: "Addr", Reason: err.Error()}
+		}
+	}
+	var auth []string
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{"Token", conf.Token != ""},
+		{"Username", conf.Username != ""},
+		{"NKeySeedFile", conf.NKeySeedFile != ""},
+		{"CredentialsFile", conf.CredentialsFile != ""},
+	} {
+		if field.set {
+			auth = append(auth, field.name)
+		}
+	}
+	if len(auth) > 1 {
+		return &ConfigError{Field: auth[1], Reason: "cannot be used with " + auth[0]}
+	}
+	if conf.Password != "" && conf.Username == "" {
+		return &ConfigError{Field: "Username", Reason: "required when Password is set"}
+	}
+	if conf.EmbeddedServerPort > 0 {
+		// 嵌入服务只支持 Token 和用户名密码认证
+		switch {
+		case conf.NKeySeedFile != "":
+			return &ConfigError{Field: "NKeySeedFile", Reason: "not supported with EmbeddedServerPort"}
+		case conf.CredentialsFile != "":
+			return &ConfigError{Field: "CredentialsFile", Reason: "not supported with EmbeddedServerPort"}
+		case conf.TLS != nil:
+			return &ConfigError{Field: "TLS", Reason: "not supported with EmbeddedServerPort"}
+		}
+	}
+	if err := checkFile("NKeySeedFile", conf.NKeySeedFile); err != nil {
+		return err
+	}
+	if err := checkFile("CredentialsFile", conf.CredentialsFile); err != nil {
+		return err
+	}
+	if conf.TLS != nil {
+		if (conf.TLS.CertFile == "") != (conf.TLS.KeyFile == "") {
+			if conf.TLS.CertFile == "" {
+				return &ConfigError{Field: "TLS.CertFile", Reason: "required when TLS.KeyFile is set"}
+			}
+			return &ConfigError{Field: "TLS.KeyFile", Reason: "required when TLS.CertFile is set"}
+		}
+		if err := checkFile("TLS.CAFile", conf.TLS.CAFile); err != nil {
+			return err
+		}
+		if err := checkFile("TLS.CertFile", conf.TLS.CertFile); err != nil {
+			return err
+		}
+		if err := checkFile("TLS.KeyFile", conf.TLS.KeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateURL 与 nats 客户端一样把没有协议的地址当作 nats://, 如 "127.0.0.1:4222"
+func validateURL(addr string) error {
+	if !strings.Contains(addr, "://") {
+		addr = "nats://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "nats", "tls", "ws", "wss":
+	default:
+		return fmt.Errorf("unsupported scheme %q in %q", u.Scheme, addr)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", addr)
+	}
+	return nil
+}
+
+func checkFile(field, file string) error {
+	if file == "" {
+		return nil
+	}
+	if _, err := os.Stat(file); err != nil {
+		return &ConfigError{Field: field, Reason: err.Error()}
+	}
+	return nil
+}
+
+// serverURL 多个地址用逗号连接, nats 客户端断开时依次尝试
+func serverURL(conf *Config) string {
+	if conf.EmbeddedServerPort > 0 {
+		return embeddedURL(conf)
+	}
+	if len(conf.Servers) > 0 {
+		return strings.Join(conf.Servers, ",")
+	}
+	if conf.Addr != "" {
+		return conf.Addr
+	}
+	return nats.DefaultURL
+}
+
+// authOptions 配置已通过 Validate 检查
+func authOptions(conf *Config) ([]nats.Option, error) {
+	var opts []nats.Option
+	switch {
+	case conf.Token != "":
+		opts = append(opts, nats.Token(conf.Token))
+	case conf.Username != "":
+		opts = append(opts, nats.UserInfo(conf.Username, conf.Password))
+	case conf.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(conf.NKeySeedFile)
+		if err != nil {
+			return nil, &ConfigError{Field: "NKeySeedFile", Reason: err.Error()}
+		}
+		opts = append(opts, opt)
+	case conf.CredentialsFile != "":
+		opts = append(opts, nats.UserCredentials(conf.CredentialsFile))
+	}
+	if conf.TLS != nil {
+		tlsConf, err := newTLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.Secure(tlsConf))
+	}
+	return opts, nil
+}
+
+func newTLSConfig(conf *TLSConfig) (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, &ConfigError{Field: "TLS.CAFile", Reason: err.Error()}
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, &ConfigError{Field: "TLS.CAFile", Reason: "no valid certificate found"}
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, &ConfigError{Field: "TLS.CertFile", Reason: err.Error()}
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
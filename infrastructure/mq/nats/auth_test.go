@@ -0,0 +1,149 @@
+package nats
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	for _, c := range []struct {
+		conf  Config
+		field string
+	}{
+		{Config{Servers: []string{"nats://a:4222", "http://b:4222"}}, "Servers[1]"},
+		{Config{Addr: "nats://"}, "Addr"},
+		{Config{Token: "t", CredentialsFile: "user.creds"}, "CredentialsFile"},
+		{Config{Password: "p"}, "Username"},
+		{Config{NKeySeedFile: "missing.nk"}, "NKeySeedFile"},
+		{Config{EmbeddedServerPort: 4222, TLS: &TLSConfig{}}, "TLS"},
+		{Config{TLS: &TLSConfig{CertFile: "client.pem"}}, "TLS.KeyFile"},
+		{Config{TLS: &TLSConfig{CAFile: "missing.pem"}}, "TLS.CAFile"},
+	} {
+		var confErr *ConfigError
+		if assert.True(t, errors.As(c.conf.Validate(), &confErr), c.field) {
+			assert.Equal(t, c.field, confErr.Field)
+		}
+	}
+	for _, conf := range []*Config{
+		{Servers: []string{"nats://a:4222", "tls://b:4222"}, Username: "u", Password: "p"},
+		// 没有协议的地址按 nats:// 处理
+		{Addr: "127.0.0.1:4222"},
+		{Addr: "localhost:4222"},
+		{Servers: []string{"127.0.0.1:4222", "localhost:4222"}},
+	} {
+		assert.NoError(t, conf.Validate())
+	}
+}
+
+// writeCert 生成由 ca 签名的证书, ca 为空时生成自签名的根证书
+func writeCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		ca, caKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPem, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPem, 0600))
+	cert, err := x509.ParseCertificate(der)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	writeCert(t, dir, "server", ca, caKey)
+	writeCert(t, dir, "client", ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		TLS:       true,
+		TLSVerify: true,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !assert.True(t, s.ReadyForConnections(5*time.Second), "nats server not ready") {
+		return
+	}
+	addr := fmt.Sprintf("tls://127.0.0.1:%d", s.Addr().(*net.TCPAddr).Port)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 第一个地址不可用时切换到第二个
+	broker, err := Dial(ctx, &Config{
+		Servers: []string{fmt.Sprintf("tls://127.0.0.1:%d", freePort(t)), addr},
+		TLS: &TLSConfig{
+			CAFile:   filepath.Join(dir, "ca.pem"),
+			CertFile: filepath.Join(dir, "client.pem"),
+			KeyFile:  filepath.Join(dir, "client-key.pem"),
+		},
+	}, logrus.New())
+	assert.NoError(t, err)
+	broker.Stop()
+
+	// 没有客户端证书时无法连接
+	broker, err = Dial(ctx, &Config{
+		Addr:          addr,
+		MaxReconnects: 1,
+		TLS:           &TLSConfig{CAFile: filepath.Join(dir, "ca.pem")},
+	}, logrus.New())
+	assert.Error(t, err, "expect connect error without client certificate")
+	broker.Stop()
+}
@@ -1,11 +1,58 @@
 package nats
 
 type Config struct {
-	Addr               string // nats://127.0.0.1:4222
-	MaxReconnects      int    // 5
-	ReconnectWait      int    // 2s
+	Addr               string   // nats://127.0.0.1:4222
+	Servers            []string // 集群地址, 断开时切换到其他地址, 设置时忽略 Addr
+	MaxReconnects      int      // 默认无限重连, 大于 0 时重连失败超过该次数后关闭连接
+	ReconnectWait      int      // 2s
+	ConnectTimeout     int      // 5s, NewNatsMQ 等待首次连接的时间, 超时后在后台继续连接
+	ReconnectBufSize   int      // 8MB, 未连接时缓存发布消息的字节数, 超出时发布返回错误, 小于 0 时不缓存
+	DrainTimeout       int      // 30s, Stop 等待订阅处理完成的时间
 	Token              string
 	Username           string
 	Password           string
+	NKeySeedFile       string // NKey 种子文件
+	CredentialsFile    string // JWT 用户凭证文件(.creds), 不能和 NKeySeedFile 同时使用
+	TLS                *TLSConfig
 	EmbeddedServerPort int
-}
\ No newline at end of file
+	EmbeddedServer     *EmbeddedServerConfig
+	JetStream          *JetStreamConfig // 不为空时启用持久化订阅
+}
+
+// TLSConfig 设置了 CertFile 和 KeyFile 时使用双向认证
+type TLSConfig struct {
+	CAFile             string // 为空时使用系统根证书
+	CertFile           string
+	KeyFile            string
+	ServerName         string // 为空时使用连接地址的主机名
+	InsecureSkipVerify bool
+}
+
+type JetStreamConfig struct {
+	Streams    []StreamConfig // 连接时创建或更新
+	MaxDeliver int            // 最大投递次数, 默认不限制
+	AckWait    int            // 30s, 超时未确认时重新投递
+	Backoff    []int          // Nak 后重新投递的延迟(秒), 第 n 次投递失败使用第 n 个, 超出时使用最后一个
+}
+
+type StreamConfig struct {
+	Name     string
+	Subjects []string // 支持 "foo.*" 和 "foo*" 写法
+	MaxAge   int      // 消息保留时间(秒), 默认不限制
+	MaxMsgs  int64
+	MaxBytes int64
+	Replicas int  // 1
+	Memory   bool // 使用内存存储, 默认文件存储
+}
+
+// EmbeddedServerConfig EmbeddedServerPort 大于 0 时在进程内启动 nats-server, 使用 Token/Username/Password 作为认证
+type EmbeddedServerConfig struct {
+	ServerName  string
+	Host        string   // 0.0.0.0
+	JetStream   bool     // 配置了 Config.JetStream 时自动开启
+	StoreDir    string   // JetStream 存储目录, 默认系统临时目录
+	LeafRemotes []string // 作为叶子节点连接的上游地址, nats-leaf://host:7422
+	ClusterName string
+	ClusterPort int      // 大于 0 时开启集群
+	Routes      []string // 集群路由地址, nats-route://host:6222
+}
@@ -0,0 +1,145 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// startEmbeddedServer 在进程内启动 nats-server, 客户端连接到该服务
+func (mq *Nats) startEmbeddedServer(conf *Config) error {
+	var es EmbeddedServerConfig
+	if conf.EmbeddedServer != nil {
+		es = *conf.EmbeddedServer
+	}
+	opts := &server.Options{
+		ServerName:    es.ServerName,
+		Host:          es.Host,
+		Port:          conf.EmbeddedServerPort,
+		Authorization: conf.Token,
+		Username:      conf.Username,
+		Password:      conf.Password,
+		NoSigs:        true,
+	}
+	if es.JetStream || conf.JetStream != nil {
+		opts.JetStream = true
+		opts.StoreDir = es.StoreDir
+	}
+	for _, remote := range es.LeafRemotes {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return fmt.Errorf("invalid leaf remote %q: %w", remote, err)
+		}
+		opts.LeafNode.Remotes = append(opts.LeafNode.Remotes, &server.RemoteLeafOpts{URLs: []*url.URL{u}})
+	}
+	if es.ClusterPort > 0 {
+		opts.Cluster.Name = es.ClusterName
+		opts.Cluster.Host = es.Host
+		opts.Cluster.Port = es.ClusterPort
+		for _, route := range es.Routes {
+			u, err := url.Parse(route)
+			if err != nil {
+				return fmt.Errorf("invalid cluster route %q: %w", route, err)
+			}
+			opts.Routes = append(opts.Routes, u)
+		}
+	}
+	s, err := server.NewServer(opts)
+	if err != nil {
+		return err
+	}
+	s.SetLoggerV2(&serverLogger{mq.logger}, false, false, false)
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		s.Shutdown()
+		return errors.New("embedded nats server not ready")
+	}
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	select {
+	case <-mq.stop:
+		s.Shutdown()
+		return nil
+	default:
+	}
+	mq.server = s
+	return nil
+}
+
+// runEmbeddedServer 启动失败时按 ReconnectWait 重试, 直到启动成功或 Stop
+func (mq *Nats) runEmbeddedServer(conf *Config) {
+	reconnectWait := conf.ReconnectWait
+	if reconnectWait <= 0 {
+		reconnectWait = 2
+	}
+	for {
+		select {
+		case <-mq.stop:
+			return
+		case <-time.After(time.Duration(reconnectWait) * time.Second):
+		}
+		err := mq.startEmbeddedServer(conf)
+		if err == nil {
+			return
+		}
+		mq.logger.WithError(err).Error("start embedded nats server error")
+	}
+}
+
+// embeddedURL 嵌入服务的本地连接地址, 监听所有地址时通过回环地址连接
+func embeddedURL(conf *Config) string {
+	host := "127.0.0.1"
+	if conf.EmbeddedServer != nil {
+		if ip := net.ParseIP(conf.EmbeddedServer.Host); ip != nil && !ip.IsUnspecified() {
+			host = ip.String()
+		} else if ip == nil && conf.EmbeddedServer.Host != "" {
+			host = conf.EmbeddedServer.Host
+		}
+	}
+	return "nats://" + net.JoinHostPort(host, strconv.Itoa(conf.EmbeddedServerPort))
+}
+
+func (mq *Nats) shutdownEmbeddedServer() {
+	mq.mu.Lock()
+	s := mq.server
+	mq.server = nil
+	mq.mu.Unlock()
+	if s != nil {
+		s.Shutdown()
+		s.WaitForShutdown()
+	}
+}
+
+// serverLogger 把 nats-server 的日志输出到 logrus
+type serverLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l *serverLogger) Noticef(format string, v ...interface{}) {
+	l.logger.Infof(format, v...)
+}
+
+func (l *serverLogger) Warnf(format string, v ...interface{}) {
+	l.logger.Warnf(format, v...)
+}
+
+func (l *serverLogger) Fatalf(format string, v ...interface{}) {
+	l.logger.Errorf(format, v...)
+}
+
+func (l *serverLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf(format, v...)
+}
+
+func (l *serverLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debugf(format, v...)
+}
+
+func (l *serverLogger) Tracef(format string, v ...interface{}) {
+	l.logger.Debugf(format, v...)
+}
@@ -0,0 +1,83 @@
+package nats
+
+import (
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestEmbeddedServer(t *testing.T) {
+	leafPort := freePort(t)
+	hub, err := server.NewServer(&server.Options{
+		Host:     "127.0.0.1",
+		Port:     -1,
+		LeafNode: server.LeafNodeOpts{Host: "127.0.0.1", Port: leafPort},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go hub.Start()
+	defer hub.Shutdown()
+	if !assert.True(t, hub.ReadyForConnections(5*time.Second), "hub not ready") {
+		return
+	}
+	hubConn, err := nats.Connect(hub.ClientURL())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer hubConn.Close()
+	upstream, err := hubConn.SubscribeSync("edge.>")
+	assert.NoError(t, err)
+	assert.NoError(t, hubConn.Flush())
+
+	port := freePort(t)
+	broker := NewNatsMQ(&Config{
+		Token:              "secret",
+		EmbeddedServerPort: port,
+		EmbeddedServer: &EmbeddedServerConfig{
+			Host:        "127.0.0.1",
+			StoreDir:    t.TempDir(),
+			LeafRemotes: []string{fmt.Sprintf("nats-leaf://127.0.0.1:%d", leafPort)},
+		},
+		JetStream: &JetStreamConfig{
+			Streams: []StreamConfig{{Name: "edge", Subjects: []string{"edge.*"}}},
+		},
+	}, logrus.New())
+	// 等待叶子节点连接到上游
+	assert.Eventually(t, func() bool { return hub.NumLeafNodes() > 0 }, 5*time.Second, 50*time.Millisecond, "leaf node not connected")
+	_, err = nats.Connect(fmt.Sprintf("nats://127.0.0.1:%d", port))
+	assert.Error(t, err, "expect authorization error")
+
+	received := make(chan interface{}, 1)
+	_, err = broker.Subscribe("edge.*", func(topic string, message interface{}) {
+		received <- message
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, broker.Publish("edge.d1", "online"))
+	select {
+	case message := <-received:
+		assert.Equal(t, "online", message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	_, err = upstream.NextMsg(5 * time.Second)
+	assert.NoError(t, err, "message not forwarded to upstream")
+
+	broker.Stop()
+	_, err = net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	assert.Error(t, err, "expect embedded server shut down")
+}
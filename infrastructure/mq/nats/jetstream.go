@@ -0,0 +1,185 @@
+package nats
+
+import (
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats.go"
+	"strings"
+	"time"
+)
+
+var errJetStreamDisabled = errors.New("nats jetstream not enabled")
+
+// ErrJetStreamNotReady 已配置 JetStream 但尚未连接或 stream 创建失败, 连接成功后重试
+var ErrJetStreamNotReady = errors.New("nats jetstream not ready: not connected yet")
+
+var _ mq.DurableConsumer = (*Nats)(nil)
+
+// setupJetStream 创建或更新配置中的 stream
+func (mq *Nats) setupJetStream(nc *nats.Conn, conf *JetStreamConfig) error {
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+	for _, stream := range conf.Streams {
+		if err = addOrUpdateStream(js, stream); err != nil {
+			return err
+		}
+	}
+	mq.mu.Lock()
+	mq.js = js
+	mq.mu.Unlock()
+	return nil
+}
+
+// jetStream stream 创建成功前返回 nil
+func (mq *Nats) jetStream() nats.JetStreamContext {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	return mq.js
+}
+
+func addOrUpdateStream(js nats.JetStreamContext, conf StreamConfig) error {
+	cfg := &nats.StreamConfig{
+		Name:     conf.Name,
+		MaxAge:   time.Duration(conf.MaxAge) * time.Second,
+		MaxMsgs:  conf.MaxMsgs,
+		MaxBytes: conf.MaxBytes,
+		Replicas: conf.Replicas,
+		Storage:  nats.FileStorage,
+	}
+	if cfg.MaxMsgs == 0 {
+		cfg.MaxMsgs = -1
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = -1
+	}
+	if conf.Memory {
+		cfg.Storage = nats.MemoryStorage
+	}
+	for _, subject := range conf.Subjects {
+		cfg.Subjects = append(cfg.Subjects, ChangeTopic(subject))
+	}
+	if _, err := js.StreamInfo(conf.Name); err == nil {
+		_, err = js.UpdateStream(cfg)
+		return err
+	}
+	_, err := js.AddStream(cfg)
+	return err
+}
+
+// DurableSubscribe 使用临时消费者订阅 stream, 只在订阅期间保留投递进度, 见 mq.DurableConsumer
+func (mq *Nats) DurableSubscribe(topic string, cb mq.DurableCallBack) (mq.Subscriber, error) {
+	return mq.durableSubscribe(topic, "", cb)
+}
+
+// DurableGroupSubscribe 组名作为持久化消费者名称, 消费者重启后从上次确认的位置继续投递
+// 同一组只能订阅同一个主题
+func (mq *Nats) DurableGroupSubscribe(topic, group string, cb mq.DurableCallBack) (mq.Subscriber, error) {
+	if group == "" {
+		return nil, errors.New("group is required")
+	}
+	return mq.durableSubscribe(topic, group, cb)
+}
+
+func (mq *Nats) durableSubscribe(topic, group string, cb mq.DurableCallBack) (mq.Subscriber, error) {
+	if mq.jsConf == nil {
+		return nil, errJetStreamDisabled
+	}
+	js := mq.jetStream()
+	if js == nil {
+		return nil, ErrJetStreamNotReady
+	}
+	subject := ChangeTopic(topic)
+	opts := []nats.SubOpt{nats.ManualAck(), nats.AckExplicit()}
+	if mq.jsConf.MaxDeliver > 0 {
+		opts = append(opts, nats.MaxDeliver(mq.jsConf.MaxDeliver))
+	}
+	if mq.jsConf.AckWait > 0 {
+		opts = append(opts, nats.AckWait(time.Duration(mq.jsConf.AckWait)*time.Second))
+	}
+	backoff := make([]time.Duration, len(mq.jsConf.Backoff))
+	for i, seconds := range mq.jsConf.Backoff {
+		backoff[i] = time.Duration(seconds) * time.Second
+	}
+	handler := func(msg *nats.Msg) {
+		var message interface{}
+		if err := encoder.Decode(msg.Subject, msg.Data, &message); err != nil {
+			mq.logger.WithError(err).Errorf("decode message from %s error", msg.Subject)
+			_ = msg.Term()
+			return
+		}
+		cb(msg.Subject, message, &delivery{msg: msg, backoff: backoff})
+	}
+	var sub *nats.Subscription
+	var err error
+	if group == "" {
+		sub, err = js.Subscribe(subject, handler, opts...)
+	} else {
+		opts = append(opts, nats.Durable(durableName(group)))
+		sub, err = js.QueueSubscribe(subject, group, handler, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &durableSubscription{sub}, nil
+}
+
+// durableName 持久化消费者名称不能包含 "." "*" ">"
+func durableName(group string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_").Replace(group)
+}
+
+// durableSubscription Unsubscribe 时保留持久化消费者, 只停止接收消息
+type durableSubscription struct {
+	sub *nats.Subscription
+}
+
+func (s *durableSubscription) Unsubscribe() error {
+	return s.sub.Drain()
+}
+
+type delivery struct {
+	msg     *nats.Msg
+	backoff []time.Duration
+}
+
+func (d *delivery) Ack() error {
+	return d.msg.Ack()
+}
+
+// Nak 服务端不支持延迟重新投递, 延迟时间到后再发送 Nak
+// 延迟超过确认超时时间时以确认超时为准
+func (d *delivery) Nak() error {
+	delay := d.delay()
+	if delay <= 0 {
+		return d.msg.Nak()
+	}
+	time.AfterFunc(delay, func() {
+		_ = d.msg.Nak()
+	})
+	return nil
+}
+
+func (d *delivery) Term() error {
+	return d.msg.Term()
+}
+
+func (d *delivery) Attempt() int {
+	meta, err := d.msg.MetaData()
+	if err != nil {
+		return 1
+	}
+	return int(meta.Delivered)
+}
+
+func (d *delivery) delay() time.Duration {
+	if len(d.backoff) == 0 {
+		return 0
+	}
+	i := d.Attempt() - 1
+	if i >= len(d.backoff) {
+		i = len(d.backoff) - 1
+	}
+	return d.backoff[i]
+}
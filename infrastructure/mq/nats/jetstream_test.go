@@ -0,0 +1,131 @@
+package nats
+
+import (
+	"encoding/json"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func runJetStreamServer(t *testing.T) *server.Server {
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	go s.Start()
+	if !assert.True(t, s.ReadyForConnections(5*time.Second), "nats server not ready") {
+		t.FailNow()
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+type received struct {
+	message  interface{}
+	attempt  int
+	delivery mq.Delivery
+}
+
+func TestDurableGroupSubscribe(t *testing.T) {
+	s := runJetStreamServer(t)
+	conf := &Config{
+		Addr: s.ClientURL(),
+		JetStream: &JetStreamConfig{
+			Streams:    []StreamConfig{{Name: "telemetry", Subjects: []string{"telemetry.*"}}},
+			MaxDeliver: 2,
+			Backoff:    []int{1},
+		},
+	}
+	broker := NewNatsMQ(conf, logrus.New())
+	defer broker.Stop()
+	consumer := broker.(mq.DurableConsumer)
+
+	// 消费者上线前发布的消息
+	assert.NoError(t, broker.Publish("telemetry.d1", 1))
+	ch := make(chan received, 10)
+	cb := func(topic string, message interface{}, delivery mq.Delivery) {
+		ch <- received{message: message, attempt: delivery.Attempt(), delivery: delivery}
+	}
+	sub, err := consumer.DurableGroupSubscribe("telemetry.*", "store", cb)
+	if !assert.NoError(t, err) {
+		return
+	}
+	next := func() received {
+		select {
+		case r := <-ch:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not delivered")
+		}
+		return received{}
+	}
+
+	r := next()
+	assert.Equal(t, json.Number("1"), r.message)
+	assert.Equal(t, 1, r.attempt)
+	start := time.Now()
+	assert.NoError(t, r.delivery.Nak())
+	// Nak 后按 Backoff 延迟重新投递同一条消息
+	r = next()
+	assert.Equal(t, json.Number("1"), r.message)
+	assert.Equal(t, 2, r.attempt)
+	assert.True(t, time.Since(start) >= time.Second, "redelivered after %s", time.Since(start))
+	// 超过最大投递次数后不再投递
+	assert.NoError(t, r.delivery.Nak())
+	select {
+	case r = <-ch:
+		assert.Fail(t, "unexpected delivery", "%+v", r)
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	// 离线期间发布的消息在重新订阅后投递
+	assert.NoError(t, sub.Unsubscribe())
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, broker.Publish("telemetry.d1", 2))
+	_, err = consumer.DurableGroupSubscribe("telemetry.*", "store", cb)
+	assert.NoError(t, err)
+	r = next()
+	assert.Equal(t, json.Number("2"), r.message)
+	assert.Equal(t, 1, r.attempt)
+	assert.NoError(t, r.delivery.Ack())
+}
+
+func TestDurableSubscribe(t *testing.T) {
+	s := runJetStreamServer(t)
+	broker := NewNatsMQ(&Config{
+		Addr: s.ClientURL(),
+		JetStream: &JetStreamConfig{
+			Streams: []StreamConfig{{Name: "event", Subjects: []string{"event.*"}}},
+		},
+	}, logrus.New())
+	defer broker.Stop()
+	consumer := broker.(mq.DurableConsumer)
+	assert.NoError(t, broker.Publish("event.d1", 1))
+
+	// 临时消费者不保存投递进度, 重新订阅后从最早的消息开始投递
+	for i := 0; i < 2; i++ {
+		ch := make(chan interface{}, 1)
+		sub, err := consumer.DurableSubscribe("event.*", func(topic string, message interface{}, delivery mq.Delivery) {
+			_ = delivery.Ack()
+			ch <- message
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		select {
+		case message := <-ch:
+			assert.Equal(t, json.Number("1"), message)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not delivered")
+		}
+		assert.NoError(t, sub.Unsubscribe())
+	}
+}
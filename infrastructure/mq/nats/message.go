@@ -0,0 +1,112 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats.go"
+	"strconv"
+	"time"
+)
+
+// 消息信封的 ID 和时间戳通过头部传递
+const (
+	IDHeader        = "Mq-Id"
+	TimestampHeader = "Mq-Timestamp"
+)
+
+func (mq *Nats) PublishMsg(ctx context.Context, topic string, msg *mq.Message) error {
+	if mq.nc == nil {
+		return errors.New("broker not connected")
+	}
+	m, err := newMsg(ctx, topic, msg)
+	if err != nil {
+		return err
+	}
+	return mq.nc.PublishMsg(m)
+}
+
+func (mq *Nats) SubscribeMsg(topic string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return mq.GroupSubscribeMsg(topic, "", handler)
+}
+
+func (mq *Nats) GroupSubscribeMsg(topic, group string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	if mq.nc == nil {
+		return nil, errors.New("nats not connected")
+	}
+	return mq.nc.QueueSubscribe(ChangeTopic(topic), group, func(m *nats.Msg) {
+		ctx, msg := parseMsg(m)
+		var data interface{}
+		if err := encoder.Decode(m.Subject, m.Data, &data); err != nil {
+			mq.logger.WithError(err).Errorf("decode message from %s error", m.Subject)
+			return
+		}
+		msg.Data = data
+		handler(ctx, msg)
+	})
+}
+
+func (mq *Nats) SubscribeRaw(topic string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	return mq.GroupSubscribeRaw(topic, "", handler)
+}
+
+func (mq *Nats) GroupSubscribeRaw(topic, group string, handler mq.MsgHandler) (mq.Subscriber, error) {
+	if mq.nc == nil {
+		return nil, errors.New("nats not connected")
+	}
+	return mq.nc.QueueSubscribe(ChangeTopic(topic), group, func(m *nats.Msg) {
+		handler(parseMsg(m))
+	})
+}
+
+// newMsg 复制 msg 并补全 ID、时间戳和 ctx 中的头部后转换为 nats 消息
+func newMsg(ctx context.Context, topic string, msg *mq.Message) (*nats.Msg, error) {
+	msg = msg.Clone()
+	if msg.ID == "" {
+		msg.ID = mq.NewMessageID()
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg.Topic = topic
+	msg.Header = mq.InjectHeader(ctx, msg.Header)
+	data, err := encoder.Encode(topic, msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	m := nats.NewMsg(topic)
+	m.Data = data
+	m.Reply = msg.Reply
+	for key, value := range msg.Header {
+		m.Header[key] = []string{value}
+	}
+	m.Header[IDHeader] = []string{msg.ID}
+	m.Header[TimestampHeader] = []string{strconv.FormatInt(msg.Timestamp.UnixNano(), 10)}
+	return m, nil
+}
+
+// parseMsg 转换 nats 消息, msg.Data 为未解码的 []byte, 返回带有 PropagatedHeaders 的 ctx
+func parseMsg(m *nats.Msg) (context.Context, *mq.Message) {
+	msg := &mq.Message{
+		Topic:  m.Subject,
+		Header: make(map[string]string, len(m.Header)),
+		Data:   m.Data,
+		Reply:  m.Reply,
+	}
+	for key, values := range m.Header {
+		if len(values) == 0 {
+			continue
+		}
+		switch key {
+		case IDHeader:
+			msg.ID = values[0]
+		case TimestampHeader:
+			if ns, err := strconv.ParseInt(values[0], 10, 64); err == nil {
+				msg.Timestamp = time.Unix(0, ns)
+			}
+		default:
+			msg.Header[key] = values[0]
+		}
+	}
+	return mq.ExtractHeader(context.Background(), msg.Header), msg
+}
@@ -0,0 +1,44 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPublishMsg(t *testing.T) {
+	broker := NewNatsMQ(&Config{EmbeddedServerPort: freePort(t)}, logrus.New())
+	defer broker.Stop()
+	type result struct {
+		ctx context.Context
+		msg *mq.Message
+	}
+	received := make(chan result, 1)
+	_, err := broker.GroupSubscribeMsg("event.*", "store", func(ctx context.Context, msg *mq.Message) {
+		received <- result{ctx, msg}
+	})
+	assert.NoError(t, err)
+	ctx := mq.WithApp(mq.WithTraceID(context.Background(), "trace-1"), "app-1")
+	msg := &mq.Message{Header: map[string]string{"Kind": "alarm"}, Data: map[string]interface{}{"value": 1}}
+	assert.NoError(t, broker.PublishMsg(ctx, "event.d1", msg))
+	var r result
+	select {
+	case r = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	assert.NotEmpty(t, r.msg.ID)
+	assert.False(t, r.msg.Timestamp.IsZero())
+	// 不修改调用方的 msg
+	assert.Equal(t, &mq.Message{Header: map[string]string{"Kind": "alarm"}, Data: map[string]interface{}{"value": 1}}, msg)
+	assert.Equal(t, "event.d1", r.msg.Topic)
+	assert.Equal(t, map[string]string{"Kind": "alarm", mq.HeaderTraceID: "trace-1", mq.HeaderApp: "app-1"}, r.msg.Header)
+	assert.Equal(t, map[string]interface{}{"value": json.Number("1")}, r.msg.Data)
+	// 头部传递到 ctx
+	assert.Equal(t, "trace-1", mq.TraceID(r.ctx))
+	assert.Equal(t, "app-1", mq.App(r.ctx))
+}
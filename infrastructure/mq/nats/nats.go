@@ -1,65 +1,127 @@
 package nats
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Nats struct {
 	nc     *nats.Conn
 	ec     *nats.EncodedConn
+	jsConf *JetStreamConfig
 	logger logrus.FieldLogger
+
+	mu        sync.Mutex
+	server    *server.Server
+	js        nats.JetStreamContext
+	status    Status
+	listeners []func(Status)
+
+	connected     chan struct{}
+	connectedOnce sync.Once
+	closed        chan struct{}
+	closedOnce    sync.Once
+	stop          chan struct{}
+	stopOnce      sync.Once
 }
 
+func newNats(logger logrus.FieldLogger) *Nats {
+	return &Nats{
+		logger:    logger,
+		connected: make(chan struct{}),
+		closed:    make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Connect 连接 nats, 首次连接失败时不返回错误, 在后台按 ReconnectWait 重连
 func (mq *Nats) Connect(conf *Config) error {
-	url := conf.Addr
-	if url == "" {
-		url = nats.DefaultURL
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	auth, err := authOptions(conf)
+	if err != nil {
+		return err
 	}
 
 	opts := []nats.Option{
+		nats.RetryOnFailedConnect(true),
 		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if nc.IsClosed() || nc.IsDraining() {
+				return
+			}
 			mq.logger.Infof("Got disconnected! Reason: %q\n", err)
+			mq.setStatus(StatusReconnecting)
 		}),
 		nats.ReconnectHandler(func(nc *nats.Conn) {
 			mq.logger.Infof("Got reconnected to %v!\n", nc.ConnectedUrl())
+			mq.onConnected(nc)
 		}),
 		nats.ClosedHandler(func(nc *nats.Conn) {
 			mq.logger.Infof("Connection closed. Reason: %q\n", nc.LastError())
+			mq.setStatus(StatusClosed)
 		}),
 	}
-	if conf.MaxReconnects > 0 {
-		opts = append(opts, nats.MaxReconnects(conf.MaxReconnects))
+	// 关闭后的连接不会再重连, 默认无限重连
+	maxReconnects := conf.MaxReconnects
+	if maxReconnects <= 0 {
+		maxReconnects = -1
 	}
+	opts = append(opts, nats.MaxReconnects(maxReconnects))
 	if conf.ReconnectWait > 0 {
 		opts = append(opts, nats.ReconnectWait(time.Duration(conf.ReconnectWait)*time.Second))
 	}
-	if conf.Token != "" {
-		opts = append(opts, nats.Token(conf.Token))
+	if conf.ReconnectBufSize != 0 {
+		opts = append(opts, nats.ReconnectBufSize(conf.ReconnectBufSize))
 	}
-	if conf.Username != "" {
-		opts = append(opts, nats.UserInfo(conf.Username, conf.Password))
+	if conf.DrainTimeout > 0 {
+		opts = append(opts, nats.DrainTimeout(time.Duration(conf.DrainTimeout)*time.Second))
 	}
-	var err error
-	mq.nc, err = nats.Connect(url, opts...)
+	opts = append(opts, auth...)
+	mq.jsConf = conf.JetStream
+	nc, err := nats.Connect(serverURL(conf), opts...)
 	if err != nil {
 		return err
 	}
-	mq.ec, err = nats.NewEncodedConn(mq.nc, JSON_ENCODER)
+	mq.ec, err = nats.NewEncodedConn(nc, JSON_ENCODER)
 	if err != nil {
+		nc.Close()
 		return err
 	}
+	mq.nc = nc
+	if nc.IsConnected() {
+		mq.onConnected(nc)
+	}
 	return nil
 }
 
+// onConnected 首次连接时创建 stream, 失败时在下次重连后重试
+func (mq *Nats) onConnected(nc *nats.Conn) {
+	if mq.jsConf != nil && mq.jetStream() == nil {
+		if err := mq.setupJetStream(nc, mq.jsConf); err != nil {
+			mq.logger.WithError(err).Error("setup nats jetstream error")
+		}
+	}
+	mq.setStatus(StatusConnected)
+}
+
+// Stop 等待订阅中已收到的消息处理完成并发送缓存的消息后关闭连接
 func (mq *Nats) Stop() {
-	if mq.ec != nil {
-		mq.ec.Close()
+	mq.stopOnce.Do(func() { close(mq.stop) })
+	if mq.nc != nil {
+		if err := mq.nc.Drain(); err == nil {
+			<-mq.closed
+		}
 	}
+	mq.shutdownEmbeddedServer()
 }
 
 func (mq *Nats) Publish(topic string, data interface{}) error {
@@ -73,7 +135,7 @@ func (mq *Nats) Subscribe(topic string, fn mq.CallBack) (mq.Subscriber, error) {
 	if mq.ec == nil {
 		return nil, errors.New("nats not connected")
 	}
-	topic = changeTopic(topic)
+	topic = ChangeTopic(topic)
 	return mq.ec.Subscribe(topic, fn)
 }
 
@@ -81,11 +143,12 @@ func (mq *Nats) GroupSubscribe(topic, group string, fn mq.CallBack) (mq.Subscrib
 	if mq.ec == nil {
 		return nil, errors.New("nats not connected")
 	}
-	topic = changeTopic(topic)
+	topic = ChangeTopic(topic)
 	return mq.ec.QueueSubscribe(topic, group, fn)
 }
 
-func changeTopic(topic string) string {
+// ChangeTopic 把 "foo.*" 和 "foo*" 转换为 nats 的多级通配 "foo.>"
+func ChangeTopic(topic string) string {
 	if strings.HasSuffix(topic, ".*") {
 		return strings.TrimSuffix(topic, ".*") + ".>"
 	} else if strings.HasSuffix(topic, "*") {
@@ -94,28 +157,44 @@ func changeTopic(topic string) string {
 	return topic
 }
 
-func NewNatsMQ(conf *Config, logger logrus.FieldLogger) mq.MQ {
-	natsMQ := &Nats{
-		logger: logger,
-	}
-	reconnectWait := conf.ReconnectWait
-	if reconnectWait <= 0 {
-		reconnectWait = 2
-	}
-	var connected = make(chan bool)
-	go func() {
-		for {
-			err := natsMQ.Connect(conf)
-			if err != nil {
-				logger.WithError(err).Error("connect to mq broker error")
-				time.Sleep(time.Duration(reconnectWait) * time.Second)
-			} else {
-				break
-			}
+// Dial 启动嵌入服务并连接 nats, 等待首次连接直到 ctx 结束
+// 返回的 broker 总是非空, 配置错误时返回 *ConfigError 且 broker 处于 StatusClosed 状态
+// ctx 超时时 broker 处于 StatusConnecting 状态并在后台继续连接
+func Dial(ctx context.Context, conf *Config, logger logrus.FieldLogger) (*Nats, error) {
+	natsMQ := newNats(logger)
+	if err := conf.Validate(); err != nil {
+		natsMQ.setStatus(StatusClosed)
+		return natsMQ, err
+	}
+	if conf.EmbeddedServerPort > 0 {
+		if err := natsMQ.startEmbeddedServer(conf); err != nil {
+			logger.WithError(err).Error("start embedded nats server error")
+			go natsMQ.runEmbeddedServer(conf)
 		}
-		connected <- true
+	}
+	if err := natsMQ.Connect(conf); err != nil {
+		natsMQ.setStatus(StatusClosed)
+		return natsMQ, err
+	}
+	if err := natsMQ.WaitConnected(ctx); err != nil {
+		return natsMQ, fmt.Errorf("nats not connected: %w", err)
+	}
+	return natsMQ, nil
+}
+
+// NewNatsMQ 最多等待 ConnectTimeout, 未连接时记录日志并返回在后台重连的 broker
+func NewNatsMQ(conf *Config, logger logrus.FieldLogger) mq.MQ {
+	timeout := conf.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 5
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	natsMQ, err := Dial(ctx, conf, logger)
+	if err != nil {
+		logger.WithError(err).Error("connect to mq broker error, running in degraded mode")
+	} else {
 		logger.Info("nats server connected")
-	}()
-	<-connected
+	}
 	return natsMQ
 }
@@ -0,0 +1,79 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrorHeader 响应方返回错误时通过该头部传递错误信息
+const ErrorHeader = "Mq-Error"
+
+var encoder = &JsonEncoder{}
+
+func (mq *Nats) Request(ctx context.Context, topic string, data interface{}, reply interface{}) error {
+	if mq.nc == nil {
+		return errors.New("nats not connected")
+	}
+	payload, err := encoder.Encode(topic, data)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(topic)
+	msg.Data = payload
+	for key, value := range injectHeader(ctx) {
+		msg.Header[key] = []string{value}
+	}
+	resp, err := mq.nc.RequestMsgWithContext(ctx, msg)
+	return decodeResponse(resp, err, reply)
+}
+
+func injectHeader(ctx context.Context) map[string]string {
+	return mq.InjectHeader(ctx, nil)
+}
+
+// decodeResponse 把 nats 响应转换为 mq 的错误或解码到 reply
+func decodeResponse(resp *nats.Msg, err error, reply interface{}) error {
+	if err != nil {
+		if err == nats.ErrNoResponders {
+			return mq.ErrNoResponders
+		}
+		return err
+	}
+	if message := resp.Header.Get(ErrorHeader); message != "" {
+		return &mq.ResponseError{Message: message}
+	}
+	if reply == nil {
+		return nil
+	}
+	return encoder.Decode(resp.Subject, resp.Data, reply)
+}
+
+func (mq *Nats) Respond(topic, group string, handler mq.Responder) (mq.Subscriber, error) {
+	if mq.nc == nil {
+		return nil, errors.New("nats not connected")
+	}
+	cb := func(msg *nats.Msg) {
+		if msg.Reply == "" {
+			return
+		}
+		resp := nats.NewMsg(msg.Reply)
+		var request interface{}
+		err := encoder.Decode(msg.Subject, msg.Data, &request)
+		if err == nil {
+			var result interface{}
+			if result, err = handler(msg.Subject, request); err == nil {
+				resp.Data, err = encoder.Encode(msg.Reply, result)
+			}
+		}
+		if err != nil {
+			resp.Data = nil
+			resp.Header.Set(ErrorHeader, err.Error())
+		}
+		if err = msg.RespondMsg(resp); err != nil {
+			mq.logger.WithError(err).Error("respond to request error")
+		}
+	}
+	return mq.nc.QueueSubscribe(ChangeTopic(topic), group, cb)
+}
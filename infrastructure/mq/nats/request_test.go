@@ -0,0 +1,49 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	broker, err := Dial(ctx, &Config{
+		EmbeddedServerPort: freePort(t),
+		EmbeddedServer:     &EmbeddedServerConfig{Host: "127.0.0.1"},
+	}, logrus.New())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer broker.Stop()
+
+	assert.Equal(t, mq.ErrNoResponders, broker.Request(ctx, "device.config", nil, nil))
+
+	sub, err := broker.Respond("device.*", "edge", func(topic string, request interface{}) (interface{}, error) {
+		if topic == "device.restart" {
+			return nil, errors.New("device busy")
+		}
+		return map[string]interface{}{"topic": topic, "request": request}, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var reply map[string]interface{}
+	assert.NoError(t, broker.Request(ctx, "device.config", "read", &reply))
+	assert.Equal(t, map[string]interface{}{"topic": "device.config", "request": "read"}, reply)
+
+	// 响应方的错误通过 Mq-Error 头部传回
+	err = broker.Request(ctx, "device.restart", nil, nil)
+	var respErr *mq.ResponseError
+	if assert.True(t, errors.As(err, &respErr), "got %v", err) {
+		assert.Equal(t, "device busy", respErr.Message)
+	}
+
+	assert.NoError(t, sub.Unsubscribe())
+	assert.Equal(t, mq.ErrNoResponders, broker.Request(ctx, "device.config", nil, nil))
+}
@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"context"
+	"github.com/nats-io/nats.go"
+)
+
+// Status 客户端连接状态
+type Status int
+
+const (
+	StatusConnecting   Status = iota // 首次连接中
+	StatusConnected                  // 已连接
+	StatusReconnecting               // 断开后重连中, 发布的消息缓存在本地
+	StatusClosed                     // 已关闭或超过最大重连次数
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusConnecting:
+		return "connecting"
+	case StatusConnected:
+		return "connected"
+	case StatusReconnecting:
+		return "reconnecting"
+	case StatusClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Status 当前连接状态
+func (mq *Nats) Status() Status {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	return mq.status
+}
+
+// OnStatusChange 注册状态变化回调, 回调在 nats 的事件协程中依次执行, 不能阻塞
+func (mq *Nats) OnStatusChange(fn func(status Status)) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.listeners = append(mq.listeners, fn)
+}
+
+// WaitConnected 等待首次连接成功, 连接关闭时返回 nats.ErrConnectionClosed
+func (mq *Nats) WaitConnected(ctx context.Context) error {
+	select {
+	case <-mq.connected:
+		return nil
+	case <-mq.closed:
+		return nats.ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (mq *Nats) setStatus(status Status) {
+	mq.mu.Lock()
+	if mq.status == status {
+		mq.mu.Unlock()
+		return
+	}
+	mq.status = status
+	listeners := make([]func(Status), len(mq.listeners))
+	copy(listeners, mq.listeners)
+	mq.mu.Unlock()
+	switch status {
+	case StatusConnected:
+		mq.connectedOnce.Do(func() { close(mq.connected) })
+	case StatusClosed:
+		mq.closedOnce.Do(func() { close(mq.closed) })
+	}
+	for _, fn := range listeners {
+		fn(status)
+	}
+}
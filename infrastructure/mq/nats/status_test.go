@@ -0,0 +1,88 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDegradedStart(t *testing.T) {
+	port := freePort(t)
+	broker := NewNatsMQ(&Config{
+		Addr:           fmt.Sprintf("nats://127.0.0.1:%d", port),
+		ReconnectWait:  1,
+		ConnectTimeout: 1,
+	}, logrus.New()).(*Nats)
+	assert.Equal(t, StatusConnecting, broker.Status())
+	// 默认无限重连
+	assert.Equal(t, -1, broker.nc.Opts.MaxReconnect)
+	var mu sync.Mutex
+	var statuses []Status
+	changed := make(chan Status, 10)
+	broker.OnStatusChange(func(status Status) {
+		mu.Lock()
+		statuses = append(statuses, status)
+		mu.Unlock()
+		changed <- status
+	})
+	received := make(chan interface{}, 1)
+	_, err := broker.Subscribe("device.*", func(topic string, message interface{}) {
+		time.Sleep(100 * time.Millisecond)
+		received <- message
+	})
+	assert.NoError(t, err)
+	// 未连接时发布的消息缓存在本地, 连接后发送
+	assert.NoError(t, broker.Publish("device.d1", "buffered"))
+
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: port})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go s.Start()
+	defer s.Shutdown()
+	select {
+	case status := <-changed:
+		assert.Equal(t, StatusConnected, status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not connected")
+	}
+
+	// Stop 等待处理中的消息完成
+	broker.Stop()
+	select {
+	case message := <-received:
+		assert.Equal(t, "buffered", message)
+	default:
+		assert.Fail(t, "message not delivered before stop")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, StatusClosed, broker.Status())
+	assert.Equal(t, []Status{StatusConnected, StatusClosed}, statuses)
+}
+
+func TestJetStreamNotReady(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cb := func(topic string, message interface{}, delivery mq.Delivery) {}
+
+	broker, err := Dial(ctx, &Config{
+		Addr:      fmt.Sprintf("nats://127.0.0.1:%d", freePort(t)),
+		JetStream: &JetStreamConfig{Streams: []StreamConfig{{Name: "event", Subjects: []string{"event.*"}}}},
+	}, logrus.New())
+	assert.Error(t, err)
+	defer broker.Stop()
+	_, err = broker.DurableGroupSubscribe("event.*", "store", cb)
+	assert.Equal(t, ErrJetStreamNotReady, err)
+
+	disabled, _ := Dial(ctx, &Config{Addr: fmt.Sprintf("nats://127.0.0.1:%d", freePort(t))}, logrus.New())
+	defer disabled.Stop()
+	_, err = disabled.DurableSubscribe("event.*", cb)
+	assert.Equal(t, errJetStreamDisabled, err)
+}
@@ -0,0 +1,17 @@
+package typed
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/huskar-t/gopher/common/define/tsdb"
+)
+
+// DeviceDataHandler 接收解码后的设备数据, Points 中的数值为 json.Number
+type DeviceDataHandler func(ctx context.Context, msg *mq.Message, data *tsdb.DeviceData)
+
+// SubscribeDeviceData 订阅设备数据并解码为 tsdb.DeviceData, group 为空时为普通订阅
+func SubscribeDeviceData(consumer mq.Consumer, topic, group string, handler DeviceDataHandler, onError ErrorHandler) (mq.Subscriber, error) {
+	return GroupSubscribe(consumer, topic, group, Prototype(tsdb.DeviceData{}), func(ctx context.Context, msg *mq.Message, v interface{}) {
+		handler(ctx, msg, v.(*tsdb.DeviceData))
+	}, onError)
+}
@@ -0,0 +1,67 @@
+package typed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/huskar-t/gopher/infrastructure/json"
+	"reflect"
+)
+
+// Factory 返回一个新的指针用于解码每条消息
+type Factory func() interface{}
+
+// Handler v 为 Factory 返回的指针, 已解码完成, msg.Data 为原始的 []byte
+type Handler func(ctx context.Context, msg *mq.Message, v interface{})
+
+// ErrorHandler 处理解码失败的消息, msg.Data 为原始的 []byte
+type ErrorHandler func(ctx context.Context, msg *mq.Message, err error)
+
+// Prototype 以 prototype 的类型创建 Factory, prototype 可以是值或指针
+// 如 Prototype(tsdb.DeviceData{}) 每条消息解码为新的 *tsdb.DeviceData
+func Prototype(prototype interface{}) Factory {
+	typ := reflect.TypeOf(prototype)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return func() interface{} {
+		return reflect.New(typ).Interface()
+	}
+}
+
+// Subscribe 见 GroupSubscribe
+func Subscribe(consumer mq.Consumer, topic string, factory Factory, handler Handler, onError ErrorHandler) (mq.Subscriber, error) {
+	return GroupSubscribe(consumer, topic, "", factory, handler, onError)
+}
+
+// GroupSubscribe 订阅原始消息并解码为 factory 返回的新值, 解码失败(包括非法 JSON)时调用 onError 且不调用 handler
+func GroupSubscribe(consumer mq.Consumer, topic, group string, factory Factory, handler Handler, onError ErrorHandler) (mq.Subscriber, error) {
+	return consumer.GroupSubscribeRaw(topic, group, func(ctx context.Context, msg *mq.Message) {
+		v := factory()
+		data, _ := msg.Data.([]byte)
+		if err := Decode(data, v); err != nil {
+			if onError != nil {
+				onError(ctx, msg, err)
+			}
+			return
+		}
+		handler(ctx, msg, v)
+	})
+}
+
+// Decode 把 JSON 消息解码到 to 指向的类型, 数值解码为 json.Number
+func Decode(data []byte, to interface{}) error {
+	if v := reflect.ValueOf(to); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("typed: decode target must be a non-nil pointer, got %T", to)
+	}
+	if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return fmt.Errorf("typed: empty message for %T", to)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(to); err != nil {
+		return fmt.Errorf("typed: decode %T: %w", to, err)
+	}
+	return nil
+}
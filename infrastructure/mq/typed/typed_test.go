@@ -0,0 +1,92 @@
+package typed
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/huskar-t/gopher/common/define/mq"
+	"github.com/huskar-t/gopher/common/define/tsdb"
+	"github.com/huskar-t/gopher/infrastructure/mq/memory"
+	natsmq "github.com/huskar-t/gopher/infrastructure/mq/nats"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSubscribeDeviceData(t *testing.T) {
+	m := memory.NewMemoryMQ()
+	defer m.Stop()
+	var got []*tsdb.DeviceData
+	var errs []error
+	_, err := SubscribeDeviceData(m, "device.*", "", func(ctx context.Context, msg *mq.Message, data *tsdb.DeviceData) {
+		got = append(got, data)
+	}, func(ctx context.Context, msg *mq.Message, err error) {
+		errs = append(errs, err)
+	})
+	assert.NoError(t, err)
+	ts := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	for _, data := range []interface{}{
+		&tsdb.DeviceData{EdgeID: "e1", DeviceID: "d1", TS: ts, Points: map[string]interface{}{"v": 1.5}},
+		&tsdb.DeviceData{EdgeID: "e1", DeviceID: "d2", TS: ts, Points: map[string]interface{}{"v": 2}},
+		"not a device",
+		nil,
+	} {
+		assert.NoError(t, m.Publish("device.data", data))
+	}
+	assert.Len(t, errs, 2)
+	if !assert.Len(t, got, 2) {
+		return
+	}
+	// 每条消息解码为新的值
+	assert.False(t, got[0] == got[1])
+	assert.Equal(t, "d2", got[1].DeviceID)
+	assert.True(t, got[1].TS.Equal(ts))
+	assert.Equal(t, json.Number("2"), got[1].Points["v"])
+}
+
+func TestInvalidJSON(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !assert.True(t, s.ReadyForConnections(5*time.Second), "nats server not ready") {
+		return
+	}
+	broker := natsmq.NewNatsMQ(&natsmq.Config{Addr: s.ClientURL()}, logrus.New())
+	defer broker.Stop()
+
+	type result struct {
+		msg *mq.Message
+		err error
+	}
+	received := make(chan result, 2)
+	_, err = SubscribeDeviceData(broker, "device.*", "store", func(ctx context.Context, msg *mq.Message, data *tsdb.DeviceData) {
+		received <- result{msg: msg}
+	}, func(ctx context.Context, msg *mq.Message, err error) {
+		received <- result{msg: msg, err: err}
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 其他客户端发布的非法 JSON 也会交给 onError
+	nc, err := nats.Connect(s.ClientURL())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer nc.Close()
+	assert.NoError(t, nc.Publish("device.data", []byte(`{"deviceID": `)))
+	assert.NoError(t, nc.Flush())
+	select {
+	case r := <-received:
+		assert.Error(t, r.err)
+		assert.Equal(t, []byte(`{"deviceID": `), r.msg.Data)
+		assert.Equal(t, "device.data", r.msg.Topic)
+	case <-time.After(5 * time.Second):
+		t.Fatal("onError not called")
+	}
+}
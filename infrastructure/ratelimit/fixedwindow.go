@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/cache"
+	"strconv"
+	"time"
+)
+
+// FixedWindow 固定窗口限流, 基于 cache.Cache 的计数实现, 不依赖具体的缓存后端
+type FixedWindow struct {
+	cache cache.Cache
+	limit Limit
+}
+
+func NewFixedWindow(c cache.Cache, limit Limit) (*FixedWindow, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &FixedWindow{
+		cache: c,
+		limit: limit,
+	}, nil
+}
+
+func (l *FixedWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *FixedWindow) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	now := time.Now()
+	window := now.Truncate(l.limit.Period)
+	resetAfter := window.Add(l.limit.Period).Sub(now)
+	count, err := l.cache.IncrByWithExpiration(ctx, key+":"+strconv.FormatInt(window.Unix(), 10), n, l.limit.Period)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Allowed:    count <= l.limit.Rate,
+		Limit:      l.limit.Rate,
+		Remaining:  l.limit.Rate - count,
+		ResetAfter: resetAfter,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if !result.Allowed {
+		result.RetryAfter = resetAfter
+	}
+	return result, nil
+}
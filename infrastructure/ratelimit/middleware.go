@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/huskar-t/gopher/infrastructure/log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// KeyFunc 返回请求的限流键, 返回空字符串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// ByClientIP 按客户端 IP 限流
+func ByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByAccessKey 按 AccessKey 请求头限流
+func ByAccessKey(c *gin.Context) string {
+	accessKey := c.GetHeader("AccessKey")
+	if accessKey == "" {
+		accessKey = c.GetHeader("X-AccessKey")
+	}
+	if accessKey == "" {
+		return ""
+	}
+	return "ak:" + accessKey
+}
+
+// ByContextKey 按前置中间件写入 gin.Context 的值限流, 例如认证后的用户 ID
+func ByContextKey(key string) KeyFunc {
+	return func(c *gin.Context) string {
+		value := c.GetString(key)
+		if value == "" {
+			return ""
+		}
+		return key + ":" + value
+	}
+}
+
+// Middleware gin 限流中间件, 超出限制时返回 429
+// 限流器出错时放行请求, 避免 redis 故障导致所有接口不可用
+func Middleware(limiter Limiter, keyFunc KeyFunc) gin.HandlerFunc {
+	logger := log.GetLogger("ratelimit")
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := limiter.Allow(c.Request.Context(), key)
+		if err != nil {
+			logger.WithError(err).Error("rate limit error")
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	redisBase "github.com/go-redis/redis/v8"
+	"time"
+)
+
+// Limit 每 Period 允许 Rate 次请求, Burst 为令牌桶容量, 为 0 时等于 Rate
+type Limit struct {
+	Rate   int64
+	Period time.Duration
+	Burst  int64
+}
+
+func PerSecond(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+func PerMinute(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+func PerHour(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// validate Period 为 0 时固定窗口每次请求都是新窗口, 令牌桶速率为无穷大, 都不会限流
+func (l Limit) validate() error {
+	if l.Rate <= 0 {
+		return fmt.Errorf("ratelimit: Rate %d must be positive", l.Rate)
+	}
+	if l.Period <= 0 {
+		return fmt.Errorf("ratelimit: Period %s must be positive", l.Period)
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("ratelimit: Burst %d is invalid", l.Burst)
+	}
+	return nil
+}
+
+func (l Limit) burst() int64 {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration // 被拒绝时距离下次允许的时间
+	ResetAfter time.Duration // 距离额度完全恢复的时间
+}
+
+// Limiter 分布式限流器
+type Limiter interface {
+	Allow(ctx context.Context, key string) (*Result, error)
+	AllowN(ctx context.Context, key string, n int64) (*Result, error)
+}
+
+func runScript(ctx context.Context, script *redisBase.Script, client redisBase.UniversalClient, keys []string, args ...interface{}) ([]interface{}, error) {
+	v, err := script.Run(ctx, client, keys, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values, ok := v.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("ratelimit: unexpected script result %v", v)
+	}
+	return values, nil
+}
+
+func parseResult(values []interface{}, limit int64) (*Result, error) {
+	if len(values) != 4 {
+		return nil, fmt.Errorf("ratelimit: unexpected script result %v", values)
+	}
+	var ints [4]int64
+	for i, value := range values {
+		n, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("ratelimit: unexpected script result %v", values)
+		}
+		ints[i] = n
+	}
+	return &Result{
+		Allowed:    ints[0] == 1,
+		Limit:      limit,
+		Remaining:  ints[1],
+		RetryAfter: time.Duration(ints[2]) * time.Microsecond,
+		ResetAfter: time.Duration(ints[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	redisBase "github.com/go-redis/redis/v8"
+	"github.com/huskar-t/gopher/infrastructure/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRedis 返回 miniredis 及其客户端, 脚本使用的 TIME 由 s.SetTime 控制
+func newRedis(t *testing.T) (*miniredis.Miniredis, redisBase.UniversalClient) {
+	s, err := miniredis.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(s.Close)
+	client := redisBase.NewClient(&redisBase.Options{Addr: s.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return s, client
+}
+
+func TestLimitValidate(t *testing.T) {
+	for _, limit := range []Limit{
+		{Rate: 0, Period: time.Second},
+		{Rate: -1, Period: time.Second},
+		{Rate: 1, Period: 0},
+		{Rate: 1, Period: -time.Second},
+		{Rate: 1, Period: time.Second, Burst: -1},
+	} {
+		_, err := NewFixedWindow(nil, limit)
+		assert.Error(t, err, "%+v", limit)
+		_, err = NewSlidingWindow(nil, "", limit)
+		assert.Error(t, err, "%+v", limit)
+		_, err = NewTokenBucket(nil, "", limit)
+		assert.Error(t, err, "%+v", limit)
+	}
+	_, err := NewTokenBucket(nil, "", PerSecond(10))
+	assert.NoError(t, err)
+}
+
+func TestParseResult(t *testing.T) {
+	result, err := parseResult([]interface{}{int64(1), int64(9), int64(0), int64(100)}, 10)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(9), result.Remaining)
+	assert.Equal(t, 100*time.Microsecond, result.ResetAfter)
+
+	_, err = parseResult([]interface{}{int64(1), "9", int64(0), int64(100)}, 10)
+	assert.Error(t, err)
+	_, err = parseResult([]interface{}{int64(1)}, 10)
+	assert.Error(t, err)
+}
+
+func TestFixedWindow(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache("ratelimit", time.Minute, 0, 0)
+	defer c.Close()
+	l, err := NewFixedWindow(c, PerHour(2))
+	assert.NoError(t, err)
+
+	for i := int64(1); i >= 0; i-- {
+		result, err := l.Allow(ctx, "k")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result, err := l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, int64(0), result.Remaining)
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= time.Hour)
+
+	// 不同的键单独计数
+	result, err = l.Allow(ctx, "other")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+type errLimiter struct{}
+
+func (errLimiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (errLimiter) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := memory.NewCache("ratelimit", time.Minute, 0, 0)
+	defer c.Close()
+	l, err := NewFixedWindow(c, PerHour(1))
+	assert.NoError(t, err)
+
+	newRouter := func(limiter Limiter) *gin.Engine {
+		router := gin.New()
+		router.Use(Middleware(limiter, ByAccessKey))
+		router.GET("/", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+	do := func(router *gin.Engine, accessKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accessKey != "" {
+			req.Header.Set("AccessKey", accessKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter(l)
+	w := do(router, "a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = do(router, "a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// 没有限流键时不限流
+	for i := 0; i < 3; i++ {
+		w = do(router, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// 限流器出错时放行
+	w = do(newRouter(errLimiter{}), "a")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
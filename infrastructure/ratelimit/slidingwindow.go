@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	redisBase "github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript 滑动窗口计数, 当前窗口计数加上一窗口按剩余比例加权的计数
+// 所有窗口保存在同一个 hash 中, 集群模式下只涉及一个 slot
+var slidingWindowScript = redisBase.NewScript(`
+redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local cur = math.floor(now / window)
+local elapsed = now % window
+local state = redis.call('HMGET', KEYS[1], tostring(cur), tostring(cur - 1))
+local curCount = tonumber(state[1]) or 0
+local prevCount = tonumber(state[2]) or 0
+local estimated = prevCount * (window - elapsed) / window + curCount
+local allowed = 0
+local retry = 0
+if estimated + cost <= limit then
+	redis.call('HINCRBY', KEYS[1], tostring(cur), cost)
+	redis.call('HDEL', KEYS[1], tostring(cur - 2))
+	estimated = estimated + cost
+	allowed = 1
+elseif curCount + cost > limit or prevCount == 0 then
+	retry = window - elapsed
+else
+	local weight = (limit - cost - curCount) / prevCount
+	retry = math.max(0, math.ceil(window - window * weight - elapsed))
+end
+redis.call('PEXPIRE', KEYS[1], math.ceil(window * 2 / 1000))
+return {allowed, math.max(0, math.floor(limit - estimated)), retry, window - elapsed}
+`)
+
+// SlidingWindow 滑动窗口限流, 任意 Period 长度的时间段内请求数不超过 Rate
+type SlidingWindow struct {
+	client redisBase.UniversalClient
+	prefix string
+	limit  Limit
+}
+
+func NewSlidingWindow(client redisBase.UniversalClient, prefix string, limit Limit) (*SlidingWindow, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &SlidingWindow{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+	}, nil
+}
+
+func (l *SlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *SlidingWindow) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	window := l.limit.Period.Microseconds()
+	values, err := runScript(ctx, slidingWindowScript, l.client, []string{l.prefix + key}, l.limit.Rate, window, n)
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values, l.limit.Rate)
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	s, client := newRedis(t)
+	l, err := NewSlidingWindow(client, "rl:", PerSecond(4))
+	assert.NoError(t, err)
+	start := time.Unix(1000, 0)
+	s.SetTime(start)
+
+	for i := int64(3); i >= 0; i-- {
+		result, err := l.Allow(ctx, "k")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(4), result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+	// 当前窗口已满, 等到下一个窗口
+	result, err := l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, int64(0), result.Remaining)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, time.Second, result.ResetAfter)
+
+	// 下一个窗口过半时, 上一窗口的 4 次按一半计入
+	s.SetTime(start.Add(1500 * time.Millisecond))
+	for i := int64(1); i >= 0; i-- {
+		result, err = l.Allow(ctx, "k")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	// 再过 250ms 上一窗口只计入 1 次
+	assert.Equal(t, 250*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 500*time.Millisecond, result.ResetAfter)
+
+	s.SetTime(start.Add(1750 * time.Millisecond))
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(0), result.Remaining)
+
+	// 只保留当前和上一个窗口的计数
+	s.SetTime(start.Add(2500 * time.Millisecond))
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	keys, err := s.HKeys("rl:k")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1001", "1002"}, keys)
+
+	// 两个窗口以上没有请求时重新计数
+	s.SetTime(start.Add(5 * time.Second))
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(3), result.Remaining)
+}
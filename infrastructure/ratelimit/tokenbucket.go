@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	redisBase "github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript 令牌桶, 使用 redis 服务器时间, 返回 {是否允许, 剩余令牌, 重试等待微秒, 恢复满额微秒}
+var tokenBucketScript = redisBase.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000000)
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) * 1000000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+local reset = math.ceil((burst - tokens) * 1000000 / rate)
+redis.call('PEXPIRE', KEYS[1], math.ceil(reset / 1000) + 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// TokenBucket 令牌桶限流, 允许 Burst 以内的突发流量
+type TokenBucket struct {
+	client redisBase.UniversalClient
+	prefix string
+	limit  Limit
+}
+
+func NewTokenBucket(client redisBase.UniversalClient, prefix string, limit Limit) (*TokenBucket, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &TokenBucket{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+	}, nil
+}
+
+func (l *TokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *TokenBucket) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	rate := float64(l.limit.Rate) / l.limit.Period.Seconds()
+	values, err := runScript(ctx, tokenBucketScript, l.client, []string{l.prefix + key}, rate, l.limit.burst(), n)
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values, l.limit.burst())
+}
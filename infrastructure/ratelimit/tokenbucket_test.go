@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	s, client := newRedis(t)
+	l, err := NewTokenBucket(client, "rl:", Limit{Rate: 10, Period: time.Second, Burst: 5})
+	assert.NoError(t, err)
+	start := time.Unix(1000, 0)
+	s.SetTime(start)
+
+	// 桶满时允许 Burst 个突发请求
+	for i := int64(4); i >= 0; i-- {
+		result, err := l.Allow(ctx, "k")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(5), result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result, err := l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, int64(0), result.Remaining)
+	// 每 100ms 补充一个令牌, 500ms 补满
+	assert.Equal(t, 100*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 500*time.Millisecond, result.ResetAfter)
+
+	// 250ms 后补充 2.5 个令牌
+	s.SetTime(start.Add(250 * time.Millisecond))
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(1), result.Remaining)
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 50*time.Millisecond, result.RetryAfter)
+
+	// 超过 Burst 的请求永远不会被允许
+	result, err = l.AllowN(ctx, "k", 6)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	// 长时间空闲后令牌数不超过 Burst
+	s.SetTime(start.Add(time.Minute))
+	result, err = l.Allow(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(4), result.Remaining)
+
+	// 不同的键单独计数
+	result, err = l.AllowN(ctx, "other", 5)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(0), result.Remaining)
+}
@@ -87,6 +87,22 @@ type ClientOptions struct {
 // NewClient returns Client with a connection to the named machines. It will
 // return an error if a connection to the cluster cannot be made.
 func NewClient(ctx context.Context, machines []string, options ClientOptions) (Client, error) {
+	cli, err := NewClientV3(ctx, machines, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{
+		cli: cli,
+		ctx: ctx,
+		kv:  clientv3.NewKV(cli),
+	}, nil
+}
+
+// NewClientV3 returns a raw etcd v3 client configured from options, for
+// packages that need the full etcd API (locks, elections) with the same
+// TLS and auth settings as the registry.
+func NewClientV3(ctx context.Context, machines []string, options ClientOptions) (*clientv3.Client, error) {
 	if options.DialTimeout == 0 {
 		options.DialTimeout = 3 * time.Second
 	}
@@ -109,7 +125,7 @@ func NewClient(ctx context.Context, machines []string, options ClientOptions) (C
 		}
 	}
 
-	cli, err := clientv3.New(clientv3.Config{
+	return clientv3.New(clientv3.Config{
 		Context:           ctx,
 		Endpoints:         machines,
 		DialTimeout:       options.DialTimeout,
@@ -119,15 +135,6 @@ func NewClient(ctx context.Context, machines []string, options ClientOptions) (C
 		Username:          options.Username,
 		Password:          options.Password,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &client{
-		cli: cli,
-		ctx: ctx,
-		kv:  clientv3.NewKV(cli),
-	}, nil
 }
 
 func (c *client) LeaseID() int64 { return int64(c.leaseID) }
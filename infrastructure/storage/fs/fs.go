@@ -2,17 +2,48 @@ package fs
 
 import (
 	"context"
+	define "github.com/huskar-t/gopher/common/define/storage"
+	"github.com/huskar-t/gopher/infrastructure/json"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 )
 
+// notFoundError 对象不存在, errors.Is 同时匹配 define.ErrObjectNotFound 和 os.ErrNotExist
+// os.IsNotExist 不会解包, 需要使用 errors.Is(err, os.ErrNotExist)
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return define.ErrObjectNotFound.Error() + ": " + e.err.Error()
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return target == define.ErrObjectNotFound
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.err
+}
+
 type FileStorage struct {
 	storagePath string
 }
 
+// NewStorage 以本地目录 storagePath 作为对象存储
+func NewStorage(storagePath string) *FileStorage {
+	return &FileStorage{storagePath: storagePath}
+}
+
 func (storage *FileStorage) PutObject(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string) (err error) {
+	return storage.PutObjectWithMetadata(ctx, objectName, reader, objectSize, contentType, userTags, nil)
+}
+
+// PutObjectWithMetadata 元数据保存在同目录下的隐藏文件 ".<name>.meta" 中
+func (storage *FileStorage) PutObjectWithMetadata(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string, metadata map[string]string) (err error) {
 	filePath := path.Join(storage.storagePath, objectName)
 	if err := os.MkdirAll(path.Dir(filePath), 0666); err != nil {
 		return err
@@ -26,12 +57,48 @@ func (storage *FileStorage) PutObject(ctx context.Context, objectName string, re
 	if _, err := io.Copy(file, reader); err != nil {
 		return err
 	}
-	return nil
+	if len(metadata) == 0 {
+		return removeIfExist(metaPath(filePath))
+	}
+	canonical := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		canonical[http.CanonicalHeaderKey(k)] = v
+	}
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(metaPath(filePath), data, 0644)
+}
+
+func (storage *FileStorage) GetMetadata(ctx context.Context, objectName string) (map[string]string, error) {
+	filePath := path.Join(storage.storagePath, objectName)
+	if _, err := os.Stat(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, &notFoundError{err: err}
+		}
+		return nil, err
+	}
+	metadata := map[string]string{}
+	data, err := ioutil.ReadFile(metaPath(filePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return metadata, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
 }
 
 func (storage *FileStorage) GetObject(ctx context.Context, objectName string) ([]byte, error) {
 	file, err := os.Open(path.Join(storage.storagePath, objectName))
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &notFoundError{err: err}
+		}
 		return nil, err
 	}
 	defer file.Close()
@@ -40,12 +107,12 @@ func (storage *FileStorage) GetObject(ctx context.Context, objectName string) ([
 }
 
 func (storage *FileStorage) RemoveObject(ctx context.Context, objectName string) error {
-	return os.Remove(path.Join(storage.storagePath, objectName))
+	return remove(path.Join(storage.storagePath, objectName))
 }
 
 func (storage *FileStorage) RemoveObjects(ctx context.Context, objectNameChan <-chan string) error{
 	for objectName := range objectNameChan {
-		if err := os.Remove(path.Join(storage.storagePath, objectName)); err != nil {
+		if err := remove(path.Join(storage.storagePath, objectName)); err != nil {
 			return err
 		}
 	}
@@ -53,8 +120,24 @@ func (storage *FileStorage) RemoveObjects(ctx context.Context, objectNameChan <-
 }
 
 func (storage *FileStorage) RemoveByPrefix(ctx context.Context, prefix string) error{
-	if err := os.Remove(path.Join(storage.storagePath, prefix)); err != nil {
+	return remove(path.Join(storage.storagePath, prefix))
+}
+
+// remove 删除对象及其元数据
+func remove(filePath string) error {
+	if err := os.Remove(filePath); err != nil {
+		return err
+	}
+	return removeIfExist(metaPath(filePath))
+}
+
+func removeIfExist(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
+
+func metaPath(filePath string) string {
+	return path.Join(path.Dir(filePath), "."+path.Base(filePath)+".meta")
+}
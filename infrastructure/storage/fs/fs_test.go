@@ -0,0 +1,40 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	define "github.com/huskar-t/gopher/common/define/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestGetObjectNotFound(t *testing.T) {
+	ctx := context.Background()
+	storage := NewStorage(t.TempDir())
+	_, err := storage.GetObject(ctx, "missing")
+	assert.True(t, errors.Is(err, define.ErrObjectNotFound))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	// os.IsNotExist 不解包自定义错误
+	assert.False(t, os.IsNotExist(err))
+
+	_, err = storage.GetMetadata(ctx, "missing")
+	assert.True(t, errors.Is(err, define.ErrObjectNotFound))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestRemoveByPrefix(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := NewStorage(dir)
+	data := []byte("v")
+	assert.NoError(t, storage.PutObjectWithMetadata(ctx, "a", bytes.NewReader(data), int64(len(data)), "text/plain", nil, map[string]string{"owner": "edge"}))
+
+	// 元数据文件一起删除
+	assert.NoError(t, storage.RemoveByPrefix(ctx, "a"))
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -2,6 +2,7 @@ package minio
 
 import (
 	"context"
+	define "github.com/huskar-t/gopher/common/define/storage"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"io"
@@ -35,20 +36,43 @@ func NewStorage(conf *Config, bucketName string) (*Storage, error) {
 }
 
 func (storage *Storage) PutObject(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string) (err error) {
+	return storage.PutObjectWithMetadata(ctx, objectName, reader, objectSize, contentType, userTags, nil)
+}
+
+func (storage *Storage) PutObjectWithMetadata(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string, userTags map[string]string, metadata map[string]string) (err error) {
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
-	_, err = storage.client.PutObject(ctx, storage.bucketName, objectName, reader, objectSize, minio.PutObjectOptions{ContentType: contentType, UserTags: userTags})
+	_, err = storage.client.PutObject(ctx, storage.bucketName, objectName, reader, objectSize, minio.PutObjectOptions{ContentType: contentType, UserTags: userTags, UserMetadata: metadata})
 	return err
 }
 
+// GetMetadata 读取对象的 UserMetadata
+func (storage *Storage) GetMetadata(ctx context.Context, objectName string) (map[string]string, error) {
+	stat, err := storage.client.StatObject(ctx, storage.bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, define.ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return stat.UserMetadata, nil
+}
+
 func (storage *Storage) GetObject(ctx context.Context, objectName string) ([]byte, error) {
 	reader, err := storage.client.GetObject(ctx, storage.bucketName, objectName, minio.GetObjectOptions{})
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
-	return ioutil.ReadAll(reader)
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, define.ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return data, nil
 }
 
 func (storage *Storage) RemoveObject(ctx context.Context, objectName string) error {
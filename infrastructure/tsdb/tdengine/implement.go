@@ -42,16 +42,20 @@ func (t *TDEngine) CreateDeviceTable(edgeID string, deviceID string, points []*t
 }
 
 func (t *TDEngine) SaveTSData(edgeID string, deviceID string, data []*tsdb.PointDate) error {
+	pointIDs := make([]string, len(data))
+	for i, item := range data {
+		pointIDs[i] = item.Key
+	}
+	pts, err := t.getPointTypes(edgeID, deviceID, pointIDs)
+	if err != nil {
+		return err
+	}
 	var fields []*connector.Field
-	for _, item := range data {
-		pt, err := t.getPointType(edgeID, deviceID, item.Key)
-		if err != nil {
-			return err
-		}
+	for i, item := range data {
 		fields = append(fields, &connector.Field{
 			Key:   item.Key,
 			Value: item.Value,
-			Type:  pt,
+			Type:  pts[i],
 			TS:    item.TS,
 		})
 	}
@@ -80,7 +84,8 @@ func (t *TDEngine) DeleteDeviceData(edgeID, deviceID string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	// 删除设备所有测点的类型缓存
+	return t.typeCache.DeleteByPrefix(context.TODO(), t.pointTypeKey(edgeID, deviceID, ""))
 }
 
 func (t *TDEngine) QueryPointData(edgeID, deviceID, pointID string, offset, limit int, start, end time.Time) ([]*tsdb.PointDate, error) {
@@ -317,12 +322,34 @@ func (t *TDEngine) changeFill(fill tsdb.Fill) string {
 		return "NONE"
 	}
 }
-func (t *TDEngine) getPointType(edgeID, deviceID, pointID string) (tsdb.PointType, error) {
-	var ptStr string
-	exist,err := t.typeCache.Get(context.TODO(), fmt.Sprintf("%s:%s:%s", edgeID, deviceID, pointID), &ptStr)
+
+// getPointTypes 批量查询测点类型, 一次往返读取所有测点
+func (t *TDEngine) getPointTypes(edgeID, deviceID string, pointIDs []string) ([]tsdb.PointType, error) {
+	keys := make([]string, len(pointIDs))
+	ptStrs := make([]string, len(pointIDs))
+	to := make([]interface{}, len(pointIDs))
+	for i, pointID := range pointIDs {
+		keys[i] = t.pointTypeKey(edgeID, deviceID, pointID)
+		to[i] = &ptStrs[i]
+	}
+	exist, err := t.typeCache.MGet(context.TODO(), keys, to)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	pts := make([]tsdb.PointType, len(pointIDs))
+	for i := range pointIDs {
+		if pts[i], err = t.parsePointType(ptStrs[i], exist[i]); err != nil {
+			return nil, err
+		}
 	}
+	return pts, nil
+}
+
+func (t *TDEngine) pointTypeKey(edgeID, deviceID, pointID string) string {
+	return fmt.Sprintf("%s:%s:%s", edgeID, deviceID, pointID)
+}
+
+func (t *TDEngine) parsePointType(ptStr string, exist bool) (tsdb.PointType, error) {
 	if !exist{
 		return "", errors.New("")
 	}
@@ -0,0 +1,37 @@
+package tdengine
+
+import (
+	"context"
+	"github.com/huskar-t/gopher/common/define/tsdb"
+	"github.com/huskar-t/gopher/infrastructure/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetPointTypes(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache("pointType", time.Minute, 0, 0)
+	defer c.Close()
+	engine := &TDEngine{typeCache: c}
+	assert.NoError(t, c.Set(ctx, engine.pointTypeKey("e1", "d1", "p1"), "int"))
+	assert.NoError(t, c.Set(ctx, engine.pointTypeKey("e1", "d1", "p2"), "float"))
+	assert.NoError(t, c.Set(ctx, engine.pointTypeKey("e1", "d1", "p3"), "unknown"))
+
+	// 全部命中, 结果与测点顺序一致
+	pts, err := engine.getPointTypes("e1", "d1", []string{"p2", "p1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []tsdb.PointType{tsdb.PointTypeFloat, tsdb.PointTypeInt}, pts)
+
+	pts, err = engine.getPointTypes("e1", "d1", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, pts)
+
+	// 任一测点未命中或类型无效时返回错误
+	_, err = engine.getPointTypes("e1", "d1", []string{"p1", "missing"})
+	assert.Error(t, err)
+	_, err = engine.getPointTypes("e2", "d1", []string{"p1"})
+	assert.Error(t, err)
+	_, err = engine.getPointTypes("e1", "d1", []string{"p3"})
+	assert.Error(t, err)
+}